@@ -0,0 +1,79 @@
+package dirdiff
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// ChecksumAlgo names the hash used for Entry.Checksum, the zero value is
+// ChecksumMD5.
+type ChecksumAlgo string
+
+const (
+	ChecksumMD5    ChecksumAlgo = "md5"
+	ChecksumSHA256 ChecksumAlgo = "sha256"
+	// ChecksumBLAKE2b is BLAKE2b-256.
+	ChecksumBLAKE2b ChecksumAlgo = "blake2b"
+	// ChecksumXXH64 is the non-cryptographic XXH64, much faster than the
+	// others but only good at catching accidental changes.
+	ChecksumXXH64 ChecksumAlgo = "xxh64"
+)
+
+var (
+	ErrUnknownChecksum = errors.New("unknown checksum algorithm")
+	ErrMixedChecksum   = errors.New("entries hashed with different checksum algorithms")
+)
+
+var (
+	checksums_mutex sync.RWMutex
+	checksums       = map[ChecksumAlgo]func() hash.Hash{
+		ChecksumMD5:     md5.New,
+		ChecksumSHA256:  sha256.New,
+		ChecksumBLAKE2b: new_blake2b,
+		ChecksumXXH64:   func() hash.Hash { return new_xxh64() },
+	}
+)
+
+// RegisterChecksum makes algo usable by WalkConfig.Checksum, replacing any
+// hash registered under the same name.
+func RegisterChecksum(algo ChecksumAlgo, new_hash func() hash.Hash) {
+	checksums_mutex.Lock()
+	defer checksums_mutex.Unlock()
+
+	checksums[algo] = new_hash
+}
+
+// New returns a hash computing algo, ErrUnknownChecksum if it is not registered.
+func (algo ChecksumAlgo) New() (hash.Hash, error) {
+	checksums_mutex.RLock()
+	new_hash, ok := checksums[algo.resolve()]
+	checksums_mutex.RUnlock()
+
+	if !ok {
+		return nil, ErrUnknownChecksum
+	}
+	return new_hash(), nil
+}
+
+func (algo ChecksumAlgo) resolve() ChecksumAlgo {
+	if algo == "" {
+		return ChecksumMD5
+	}
+	return algo
+}
+
+// same_checksum tells whether a and b are the same algorithm, the zero
+// value being MD5.
+func same_checksum(a, b ChecksumAlgo) bool {
+	return a.resolve() == b.resolve()
+}
+
+func new_blake2b() hash.Hash {
+	h, _ := blake2b.New256(nil) // only fails on a key over 64 bytes
+	return h
+}
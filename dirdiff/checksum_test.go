@@ -0,0 +1,181 @@
+package dirdiff
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestXXH64(t *testing.T) {
+	long := strings.Repeat("0123456789", 10)
+	tests := []struct {
+		in  string
+		sum uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"abc", 0x44bc2cf5ad770999},
+	}
+
+	for _, tt := range tests {
+		h := new_xxh64()
+		h.Write([]byte(tt.in))
+		if h.Sum64() != tt.sum {
+			t.Errorf("xxh64(%q) = %x, want %x", tt.in, h.Sum64(), tt.sum)
+		}
+	}
+
+	h := new_xxh64()
+	h.Write([]byte(long))
+	want := h.Sum64()
+
+	for _, step := range []int{1, 7, 31, 33} {
+		h.Reset()
+		for i := 0; i < len(long); i += step {
+			end := i + step
+			if end > len(long) {
+				end = len(long)
+			}
+			h.Write([]byte(long[i:end]))
+		}
+		if h.Sum64() != want {
+			t.Errorf("writes of %d bytes: %x, want %x", step, h.Sum64(), want)
+		}
+	}
+}
+
+func TestChecksumAlgos(t *testing.T) {
+	for _, tt := range []struct {
+		algo ChecksumAlgo
+		sum  string
+	}{
+		{"", "900150983cd24fb0d6963f7d28e17f72"},
+		{ChecksumSHA256, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{ChecksumBLAKE2b, "bddd813c634239723171ef3fee98579b94964e3bb1cb3e427262c8c068d52319"},
+		{ChecksumXXH64, "44bc2cf5ad770999"},
+	} {
+		h, err := tt.algo.New()
+		if err != nil {
+			t.Errorf("%q: %v", tt.algo, err)
+			continue
+		}
+		h.Write([]byte("abc"))
+		if got := fmt.Sprintf("%x", h.Sum(nil)); got != tt.sum {
+			t.Errorf("%q(abc) = %s, want %s", tt.algo, got, tt.sum)
+		}
+	}
+
+	if _, err := ChecksumAlgo("crc32").New(); err != ErrUnknownChecksum {
+		t.Errorf("unknown algorithm: error %v, want %v", err, ErrUnknownChecksum)
+	}
+}
+
+func TestWalkDirChecksum(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "dirdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	make_tree(t, tmp, "", "a", "sub/b")
+
+	es, err := WalkDirContext(context.Background(), tmp, &WalkConfig{Checksum: ChecksumSHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256([]byte("sub/b"))
+	if e := es["sub/b"]; !bytes.Equal(e.Checksum, sum[:]) || e.ChecksumAlgo != ChecksumSHA256 {
+		t.Errorf("sub/b %s %x, want %s %x", e.ChecksumAlgo, e.Checksum, ChecksumSHA256, sum)
+	}
+	if e := es["sub"]; e.ChecksumAlgo != "" || e.Checksum != nil {
+		t.Errorf("sub has checksum %s %x", e.ChecksumAlgo, e.Checksum)
+	}
+
+	if _, err := WalkDirContext(context.Background(), tmp, &WalkConfig{Checksum: "crc0"}); err != ErrUnknownChecksum {
+		t.Errorf("error %v, want %v", err, ErrUnknownChecksum)
+	}
+
+	xes, err := WalkDirContext(context.Background(), tmp, &WalkConfig{Checksum: ChecksumXXH64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DiffEntries(es, xes); err != ErrMixedChecksum {
+		t.Errorf("error %v, want %v", err, ErrMixedChecksum)
+	}
+
+	// entries of older results have no algorithm and are MD5
+	mes, err := WalkDir(tmp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := make(map[string]Entry)
+	for k, e := range mes {
+		e.ChecksumAlgo = ""
+		old[k] = e
+	}
+	if changes, err := DiffEntries(old, mes); err != nil || len(changes) != 0 {
+		t.Errorf("changes %v, error %v", changes, err)
+	}
+}
+
+func TestTarSumFile(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "dirdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	olddir, newdir := filepath.Join(tmp, "old"), filepath.Join(tmp, "new")
+	make_tree(t, olddir, "", "a")
+	make_tree(t, newdir, "!", "a")
+
+	changes, err := DiffDirsContext(context.Background(), olddir, newdir, &WalkConfig{Checksum: ChecksumSHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(tmp, "delta")
+	if errs := Tar(newdir, changes, dst); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	b, err := ioutil.ReadFile(SumFile(dst, ChecksumSHA256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("%x  a\n", sha256.Sum256([]byte("a!"))); string(b) != want {
+		t.Errorf("%s is %q, want %q", SumFile(dst, ChecksumSHA256), b, want)
+	}
+	if _, err := os.Stat(dst + ".md5sum"); !os.IsNotExist(err) {
+		t.Errorf("%s.md5sum exists: %v", dst, err)
+	}
+}
+
+func TestNilWalkConfig(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "dirdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	make_tree(t, tmp, "", "a")
+
+	es, err := WalkDirContext(context.Background(), tmp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := es["a"]; e.ChecksumAlgo != ChecksumMD5 || e.Checksum == nil {
+		t.Errorf("a %s %x, want an md5 checksum", e.ChecksumAlgo, e.Checksum)
+	}
+
+	if changes, err := DiffDirsContext(context.Background(), tmp, tmp, nil); err != nil || len(changes) != 0 {
+		t.Errorf("changes %v, error %v", changes, err)
+	}
+}
@@ -0,0 +1,614 @@
+package dirdiff
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	delta_version = 1
+	// delta_header_name is the first member of a delta, holding its Delta.
+	delta_header_name = ".dirdiff-delta.json"
+	// stage_prefix starts the name of the staging directory Apply makes in
+	// the target, paths of deltas cannot start with it.
+	stage_prefix = ".dirdiff-stage"
+)
+
+var (
+	ErrBadDelta         = errors.New("malformed delta")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrNoOldEntry       = errors.New("modified entry without its old entry")
+	ErrTargetMismatch   = errors.New("target does not match the old side of the delta")
+	ErrUnsafePath       = errors.New("path goes through a symlink")
+)
+
+// Delta lists the changes packed by WriteDelta.
+type Delta struct {
+	Version  int           `json:"version"`
+	Checksum ChecksumAlgo  `json:"checksum"`
+	Changes  []DeltaChange `json:"changes"`
+}
+
+// DeltaChange is one change of a Delta, the entry being the deleted one for
+// ChangeTypeDelete and the new one otherwise.
+type DeltaChange struct {
+	Type ChangeType `json:"type"`
+	ManifestEntry
+	// Old is the replaced entry, which Apply expects to find in the target
+	// for ChangeTypeModify and ChangeTypeMode.
+	Old *ManifestEntry `json:"old,omitempty"`
+}
+
+// payload tells whether the content of the file of c is packed in the delta.
+func (c *DeltaChange) payload() bool {
+	return (c.Type == ChangeTypeAdd || c.Type == ChangeTypeModify) && c.Mode.IsRegular()
+}
+
+// WriteDelta packs fc, read under rootdir, into the tar file dst_path: a
+// header listing every change, deletions and mode changes included,
+// followed by the content of added and modified files. It is the input of
+// Apply.
+func WriteDelta(rootdir string, fc map[string]EntryChange, dst_path string) error {
+	algo, err := changes_checksum(fc)
+	if err != nil {
+		return err
+	}
+
+	d := Delta{
+		Version:  delta_version,
+		Checksum: algo,
+		Changes:  make([]DeltaChange, 0, len(fc)),
+	}
+	for p, c := range fc {
+		dc := DeltaChange{
+			Type:          c.Type,
+			ManifestEntry: manifest_entry(p, c.Entry),
+		}
+		if c.Old != nil {
+			old := manifest_entry(p, *c.Old)
+			dc.Old = &old
+		} else if c.Type == ChangeTypeModify || c.Type == ChangeTypeMode {
+			return ErrNoOldEntry
+		}
+		d.Changes = append(d.Changes, dc)
+	}
+	sort.Slice(d.Changes, func(i, j int) bool {
+		return d.Changes[i].Path < d.Changes[j].Path
+	})
+
+	header, err := json.Marshal(&d)
+	if err != nil {
+		return err
+	}
+
+	fp, err := os.OpenFile(dst_path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if err = write_delta(fp, rootdir, &d, header); err == nil {
+		err = fp.Close()
+	} else {
+		fp.Close()
+	}
+
+	if err != nil {
+		os.Remove(dst_path)
+	}
+	return err
+}
+
+func write_delta(w io.Writer, rootdir string, d *Delta, header []byte) error {
+	tw := tar.NewWriter(w)
+
+	err := tw.WriteHeader(&tar.Header{
+		Name:     delta_header_name,
+		Mode:     0644,
+		Size:     int64(len(header)),
+		ModTime:  time.Now(),
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := tw.Write(header); err != nil {
+		return err
+	}
+
+	for i := range d.Changes {
+		c := &d.Changes[i]
+		if !c.payload() {
+			continue
+		}
+
+		err := tw.WriteHeader(&tar.Header{
+			Name:     c.Path,
+			Mode:     int64(c.Mode.Perm()),
+			Size:     c.Size,
+			ModTime:  c.ModTime,
+			Typeflag: tar.TypeReg,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := copy_file(tw, filepath.Join(rootdir, filepath.FromSlash(c.Path)), c.Size); err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
+
+// Apply patches target_dir with the delta file written by WriteDelta. All
+// files are extracted to a staging directory inside target_dir and their
+// checksums verified, as well as those of the files to modify or delete,
+// before target_dir is touched. Then changes are made with renames; if one
+// fails, those already made are rolled back. Paths going through a symlink
+// are refused with ErrUnsafePath.
+func Apply(target_dir string, delta_file string) error {
+	target, err := filepath.Abs(target_dir)
+	if err != nil {
+		return err
+	}
+	if target, err = filepath.EvalSymlinks(target); err != nil {
+		return err
+	}
+
+	fp, err := os.Open(delta_file)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	tr := tar.NewReader(fp)
+	d, err := read_delta_header(tr)
+	if err != nil {
+		return err
+	}
+
+	stage, err := ioutil.TempDir(target, stage_prefix)
+	if err != nil {
+		return err
+	}
+
+	j := &apply_journal{
+		backup: filepath.Join(stage, "backup"),
+	}
+
+	staged, err := stage_delta(tr, d, stage)
+	if err == nil {
+		err = check_old(target, d)
+	}
+	if err == nil {
+		err = os.Mkdir(j.backup, 0700)
+	}
+	if err == nil {
+		err = commit_delta(target, d, staged, j)
+		if err != nil && !j.rollback() {
+			log.Printf("[dirdiff] Apply %s rollback incomplete, backups kept in %s", target, j.backup)
+			return err
+		}
+	}
+
+	os.RemoveAll(stage)
+	return err
+}
+
+func read_delta_header(tr *tar.Reader) (*Delta, error) {
+	hdr, err := tr.Next()
+	if err == io.EOF || err == nil && hdr.Name != delta_header_name {
+		return nil, ErrBadDelta
+	} else if err != nil {
+		return nil, err
+	}
+
+	d := new(Delta)
+	if err := json.NewDecoder(tr).Decode(d); err != nil {
+		return nil, err
+	}
+	if d.Version != delta_version {
+		return nil, ErrBadDelta
+	}
+	if _, err := d.Checksum.New(); err != nil {
+		return nil, err
+	}
+
+	symlinks := make(map[string]bool)
+	for _, c := range d.Changes {
+		p := filepath.Clean(filepath.FromSlash(c.Path))
+		if p != filepath.FromSlash(c.Path) || filepath.IsAbs(p) || p == "." || p == ".." ||
+			strings.HasPrefix(p, ".."+string(filepath.Separator)) || strings.HasPrefix(p, stage_prefix) {
+			return nil, ErrBadDelta
+		}
+		if c.payload() && c.Checksum == nil || (c.Type == ChangeTypeModify || c.Type == ChangeTypeMode) && c.Old == nil {
+			return nil, ErrBadDelta
+		}
+
+		if c.Type != ChangeTypeDelete && c.Mode&os.ModeSymlink == os.ModeSymlink {
+			symlinks[c.Path] = true
+		}
+	}
+
+	// deletions are made before the symlinks replacing their parents exist,
+	// nothing else may go through one of them
+	for _, c := range d.Changes {
+		if c.Type == ChangeTypeDelete {
+			continue
+		}
+		for p := path.Dir(c.Path); p != "."; p = path.Dir(p) {
+			if symlinks[p] {
+				return nil, ErrBadDelta
+			}
+		}
+	}
+	return d, nil
+}
+
+// stage_delta extracts the files and symlinks of d under stage, returning
+// their staged names by path.
+func stage_delta(tr *tar.Reader, d *Delta, stage string) (map[string]string, error) {
+	index := make(map[string]int)
+	for i, c := range d.Changes {
+		if c.payload() {
+			index[c.Path] = i
+		}
+	}
+
+	staged := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := index[hdr.Name]
+		if !ok || staged[hdr.Name] != "" || hdr.Size != d.Changes[i].Size {
+			return nil, ErrBadDelta
+		}
+
+		name := filepath.Join(stage, strconv.Itoa(i))
+		if err := stage_file(tr, name, &d.Changes[i], d.Checksum); err != nil {
+			return nil, err
+		}
+		staged[hdr.Name] = name
+	}
+
+	for i, c := range d.Changes {
+		if c.Type != ChangeTypeAdd && c.Type != ChangeTypeModify {
+			continue
+		}
+
+		if c.payload() {
+			if staged[c.Path] == "" {
+				return nil, ErrBadDelta
+			}
+		} else if c.Mode&os.ModeSymlink == os.ModeSymlink {
+			name := filepath.Join(stage, strconv.Itoa(i))
+			if err := os.Symlink(c.SymlinkTarget, name); err != nil {
+				return nil, err
+			}
+			staged[c.Path] = name
+		}
+	}
+	return staged, nil
+}
+
+func stage_file(r io.Reader, name string, c *DeltaChange, algo ChecksumAlgo) error {
+	h, err := algo.New()
+	if err != nil {
+		return err
+	}
+
+	fp, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(io.MultiWriter(fp, h), r)
+	if cerr := fp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(h.Sum(nil), c.Checksum) {
+		return ErrChecksumMismatch
+	}
+
+	if err := os.Chmod(name, file_mode(c.Mode)); err != nil {
+		return err
+	}
+	return os.Chtimes(name, c.ModTime, c.ModTime)
+}
+
+// check_old makes sure the files d modifies, deletes or changes the mode of
+// are in target as d knew them, ErrTargetMismatch otherwise.
+func check_old(target string, d *Delta) error {
+	for i := range d.Changes {
+		c := &d.Changes[i]
+
+		old := c.Old
+		switch c.Type {
+		case ChangeTypeDelete:
+			old = &c.ManifestEntry
+		case ChangeTypeModify, ChangeTypeMode:
+		default:
+			continue
+		}
+
+		if err := check_parents(target, c.Path); err != nil {
+			return err
+		}
+
+		p := filepath.Join(target, filepath.FromSlash(c.Path))
+		fi, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			return ErrTargetMismatch
+		} else if err != nil {
+			return err
+		}
+
+		if fi.Mode().Type() != old.Mode.Type() {
+			return ErrTargetMismatch
+		}
+		if c.Type == ChangeTypeMode {
+			if old.Mode&os.ModeSymlink == 0 && file_mode(fi.Mode()) != file_mode(old.Mode) {
+				return ErrTargetMismatch
+			}
+			continue
+		}
+
+		switch {
+		case old.Mode.IsRegular():
+			if fi.Size() != old.Size {
+				return ErrTargetMismatch
+			}
+			if old.Checksum == nil {
+				continue
+			}
+
+			sum, err := file_checksum(context.Background(), d.Checksum, p, fi.Size())
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(sum, old.Checksum) {
+				return ErrTargetMismatch
+			}
+		case old.Mode&os.ModeSymlink == os.ModeSymlink:
+			if link, err := os.Readlink(p); err != nil {
+				return err
+			} else if link != old.SymlinkTarget {
+				return ErrTargetMismatch
+			}
+		}
+	}
+	return nil
+}
+
+// check_parents refuses with ErrUnsafePath rel if one of its parent
+// directories under target is a symlink, which Apply would follow out of
+// target.
+func check_parents(target string, rel string) error {
+	p := target
+	for _, part := range strings.Split(path.Dir(rel), "/") {
+		if part == "." {
+			break
+		}
+
+		p = filepath.Join(p, part)
+		fi, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
+			return ErrUnsafePath
+		}
+	}
+	return nil
+}
+
+// commit_delta applies d to target, deletions first, deepest paths first,
+// then additions and modifications, parents first, then mode changes, those
+// of directories last, deepest first, so that a read-only directory gets
+// its content before. Every step is recorded in j to be undone.
+func commit_delta(target string, d *Delta, staged map[string]string, j *apply_journal) error {
+	for i := len(d.Changes) - 1; i >= 0; i-- {
+		c := &d.Changes[i]
+		if c.Type != ChangeTypeDelete {
+			continue
+		}
+
+		if err := check_parents(target, c.Path); err != nil {
+			return err
+		}
+
+		p := filepath.Join(target, filepath.FromSlash(c.Path))
+		fi, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+
+		if !fi.IsDir() {
+			if err := j.move_aside(p); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		j.add(func() error { return os.Mkdir(p, file_mode(fi.Mode())) })
+	}
+
+	for i := range d.Changes {
+		c := &d.Changes[i]
+		if c.Type != ChangeTypeAdd && c.Type != ChangeTypeModify {
+			continue
+		}
+
+		if err := check_parents(target, c.Path); err != nil {
+			return err
+		}
+
+		p := filepath.Join(target, filepath.FromSlash(c.Path))
+		fi, err := os.Lstat(p)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		exists := err == nil
+
+		if c.Mode.IsDir() {
+			if exists && fi.IsDir() {
+				continue
+			}
+
+			if exists {
+				if err := j.move_aside(p); err != nil {
+					return err
+				}
+			}
+			if err := os.Mkdir(p, 0700); err != nil {
+				return err
+			}
+			j.add(func() error { return os.Remove(p) })
+			continue
+		}
+
+		name := staged[c.Path]
+		if name == "" {
+			// devices, sockets and such are not carried by deltas
+			log.Printf("[dirdiff] Apply %s skipped, mode %s", c.Path, c.Mode)
+			continue
+		}
+
+		if exists {
+			if err := j.move_aside(p); err != nil {
+				return err
+			}
+		}
+		if err := os.Rename(name, p); err != nil {
+			return err
+		}
+		j.add(func() error { return os.Rename(p, name) })
+	}
+
+	for i := range d.Changes {
+		c := &d.Changes[i]
+		if c.Type != ChangeTypeMode {
+			continue
+		}
+
+		if err := check_parents(target, c.Path); err != nil {
+			return err
+		}
+
+		p := filepath.Join(target, filepath.FromSlash(c.Path))
+		fi, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink == os.ModeSymlink || fi.IsDir() {
+			continue
+		}
+
+		if err := j.chmod(p, fi.Mode(), c.Mode); err != nil {
+			return err
+		}
+	}
+
+	for i := len(d.Changes) - 1; i >= 0; i-- {
+		c := &d.Changes[i]
+		if c.Type == ChangeTypeDelete || !c.Mode.IsDir() {
+			continue
+		}
+
+		if err := check_parents(target, c.Path); err != nil {
+			return err
+		}
+
+		p := filepath.Join(target, filepath.FromSlash(c.Path))
+		fi, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			continue
+		}
+
+		if err := j.chmod(p, fi.Mode(), c.Mode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// file_mode is the part of mode os.Chmod sets.
+func file_mode(mode os.FileMode) os.FileMode {
+	return mode & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+}
+
+// apply_journal records the steps of Apply to undo them in reverse order.
+type apply_journal struct {
+	backup string
+	n      int
+	undo   []func() error
+}
+
+func (j *apply_journal) add(undo func() error) {
+	j.undo = append(j.undo, undo)
+}
+
+// move_aside renames p into the backup directory.
+func (j *apply_journal) move_aside(p string) error {
+	b := filepath.Join(j.backup, strconv.Itoa(j.n))
+	j.n++
+
+	if err := os.Rename(p, b); err != nil {
+		return err
+	}
+	j.add(func() error { return os.Rename(b, p) })
+	return nil
+}
+
+func (j *apply_journal) chmod(p string, old, mode os.FileMode) error {
+	if err := os.Chmod(p, file_mode(mode)); err != nil {
+		return err
+	}
+	j.add(func() error { return os.Chmod(p, file_mode(old)) })
+	return nil
+}
+
+// rollback undoes all steps, telling whether they all succeeded.
+func (j *apply_journal) rollback() bool {
+	ok := true
+	for i := len(j.undo) - 1; i >= 0; i-- {
+		if err := j.undo[i](); err != nil {
+			log.Printf("[dirdiff] Apply rollback error: %v", err)
+			ok = false
+		}
+	}
+	j.undo = nil
+	return ok
+}
@@ -0,0 +1,294 @@
+package dirdiff
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// delta_trees creates old and new trees under tmp and returns their diff.
+func delta_trees(t *testing.T, tmp string) (string, string, map[string]EntryChange) {
+	olddir, newdir := filepath.Join(tmp, "old"), filepath.Join(tmp, "new")
+	make_tree(t, olddir, "", "same", "modified", "deleted", "mode", "gone/a", "gone/b/c")
+	make_tree(t, newdir, "", "same", "mode", "added", "sub/added")
+	make_tree(t, newdir, "!", "modified")
+	os.Chmod(filepath.Join(newdir, "mode"), 0600)
+	os.Symlink("same", filepath.Join(newdir, "link"))
+
+	changes, err := DiffDirsContext(context.Background(), olddir, newdir, &WalkConfig{Checksum: ChecksumSHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return olddir, newdir, changes
+}
+
+func tree_state(t *testing.T, dir string) map[string]Entry {
+	es, err := WalkDirContext(context.Background(), dir, &WalkConfig{Checksum: ChecksumSHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return es
+}
+
+func TestApply(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "dirdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	olddir, newdir, changes := delta_trees(t, tmp)
+
+	delta := filepath.Join(tmp, "delta.tar")
+	if err := WriteDelta(newdir, changes, delta); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Apply(olddir, delta); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err = DiffEntries(tree_state(t, olddir), tree_state(t, newdir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, c := range changes {
+		if !c.Entry.Fileinfo.IsDir() || c.Type != ChangeTypeModify {
+			t.Errorf("%s still differs: %s", p, c.Type)
+		}
+	}
+
+	if target, err := os.Readlink(filepath.Join(olddir, "link")); err != nil || target != "same" {
+		t.Errorf("link to %q, error %v", target, err)
+	}
+
+	if names, _ := filepath.Glob(filepath.Join(olddir, stage_prefix+"*")); len(names) != 0 {
+		t.Errorf("staging left: %v", names)
+	}
+}
+
+func TestApplyChecksumMismatch(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "dirdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	olddir, newdir, changes := delta_trees(t, tmp)
+
+	// same size, different content since the diff
+	make_tree(t, newdir, "?", "modified")
+
+	delta := filepath.Join(tmp, "delta.tar")
+	if err := WriteDelta(newdir, changes, delta); err != nil {
+		t.Fatal(err)
+	}
+
+	before := tree_state(t, olddir)
+	if err := Apply(olddir, delta); err != ErrChecksumMismatch {
+		t.Fatalf("error %v, want %v", err, ErrChecksumMismatch)
+	}
+	if changes, _ := DiffEntries(before, tree_state(t, olddir)); len(changes) != 0 {
+		t.Errorf("target changed: %v", changes)
+	}
+}
+
+func TestApplyRollback(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "dirdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	olddir, newdir, changes := delta_trees(t, tmp)
+
+	// sub/added is the last addition, without its parent it fails and the
+	// deletions and additions made before are undone
+	delete(changes, "sub")
+
+	delta := filepath.Join(tmp, "delta.tar")
+	if err := WriteDelta(newdir, changes, delta); err != nil {
+		t.Fatal(err)
+	}
+
+	before := tree_state(t, olddir)
+	if err := Apply(olddir, delta); !os.IsNotExist(err) {
+		t.Fatalf("error %v, want not exist", err)
+	}
+
+	after := tree_state(t, olddir)
+	if changes, _ := DiffEntries(before, after); len(changes) != 0 {
+		t.Errorf("target changed: %v", changes)
+	}
+	for p, e := range before {
+		if a := after[p]; a.Fileinfo.Mode() != e.Fileinfo.Mode() {
+			t.Errorf("%s mode %s, want %s", p, a.Fileinfo.Mode(), e.Fileinfo.Mode())
+		}
+	}
+
+	if names, _ := filepath.Glob(filepath.Join(olddir, stage_prefix+"*")); len(names) != 0 {
+		t.Errorf("staging left: %v", names)
+	}
+}
+
+func TestApplyBadDelta(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "dirdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	make_tree(t, tmp, "", "src/a", "dst/b")
+	if err := TarDir(filepath.Join(tmp, "src"), filepath.Join(tmp, "plain.tar")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply(filepath.Join(tmp, "dst"), filepath.Join(tmp, "plain.tar")); err != ErrBadDelta {
+		t.Errorf("error %v, want %v", err, ErrBadDelta)
+	}
+}
+
+func TestApplyTargetMismatch(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "dirdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	olddir, newdir, changes := delta_trees(t, tmp)
+
+	delta := filepath.Join(tmp, "delta.tar")
+	if err := WriteDelta(newdir, changes, delta); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []string{"modified", "deleted"} {
+		// same size, changed in the target since the diff
+		make_tree(t, olddir, "", f)
+		p := filepath.Join(olddir, f)
+		b, _ := ioutil.ReadFile(p)
+		b[0] ^= 0x20
+		ioutil.WriteFile(p, b, 0644)
+
+		before := tree_state(t, olddir)
+		if err := Apply(olddir, delta); err != ErrTargetMismatch {
+			t.Fatalf("%s: error %v, want %v", f, err, ErrTargetMismatch)
+		}
+		if changes, _ := DiffEntries(before, tree_state(t, olddir)); len(changes) != 0 {
+			t.Errorf("%s: target changed: %v", f, changes)
+		}
+		make_tree(t, olddir, "", f)
+	}
+
+	// mode changed in the target since the diff
+	os.Chmod(filepath.Join(olddir, "mode"), 0640)
+	if err := Apply(olddir, delta); err != ErrTargetMismatch {
+		t.Fatalf("mode: error %v, want %v", err, ErrTargetMismatch)
+	}
+	os.Chmod(filepath.Join(olddir, "mode"), 0644)
+
+	os.Remove(filepath.Join(olddir, "deleted"))
+	if err := Apply(olddir, delta); err != ErrTargetMismatch {
+		t.Fatalf("missing file: error %v, want %v", err, ErrTargetMismatch)
+	}
+}
+
+func TestApplyReadOnlyDir(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "dirdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	olddir, newdir := filepath.Join(tmp, "old"), filepath.Join(tmp, "new")
+	make_tree(t, olddir, "", "keep/a")
+	make_tree(t, newdir, "", "keep/a", "keep/b", "ro/sub/f")
+	for _, d := range []string{"keep", "ro/sub", "ro"} {
+		os.Chmod(filepath.Join(newdir, d), 0555)
+	}
+	defer func() {
+		for _, dir := range []string{olddir, newdir} {
+			for _, d := range []string{"keep", "ro", "ro/sub"} {
+				os.Chmod(filepath.Join(dir, d), 0755)
+			}
+		}
+	}()
+
+	changes, err := DiffDirsContext(context.Background(), olddir, newdir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delta := filepath.Join(tmp, "delta.tar")
+	if err := WriteDelta(newdir, changes, delta); err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply(olddir, delta); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []string{"keep/b", "ro/sub/f"} {
+		if _, err := os.Stat(filepath.Join(olddir, f)); err != nil {
+			t.Error(err)
+		}
+	}
+	for _, d := range []string{"keep", "ro", "ro/sub"} {
+		if fi, err := os.Stat(filepath.Join(olddir, d)); err != nil || fi.Mode().Perm() != 0555 {
+			t.Errorf("%s: mode %v, error %v, want 0555", d, fi.Mode(), err)
+		}
+	}
+}
+
+func TestApplySymlinkedParent(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "dirdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	outside := filepath.Join(tmp, "outside")
+	os.Mkdir(outside, 0755)
+
+	// the delta adds a symlink to outside and a file through it
+	src := filepath.Join(tmp, "src")
+	make_tree(t, src, "", "real/x")
+	os.Symlink(filepath.Join(outside, "real"), filepath.Join(src, "a"))
+	es := tree_state(t, src)
+
+	delta := filepath.Join(tmp, "delta.tar")
+	make_tree(t, outside, "", "real/x")
+	err = WriteDelta(src, map[string]EntryChange{
+		"a":   {Type: ChangeTypeAdd, Entry: es["a"]},
+		"a/x": {Type: ChangeTypeAdd, Entry: es["real/x"]},
+	}, delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(filepath.Join(outside, "real"))
+
+	dst := filepath.Join(tmp, "dst")
+	os.Mkdir(dst, 0755)
+	if err := Apply(dst, delta); err != ErrBadDelta {
+		t.Errorf("error %v, want %v", err, ErrBadDelta)
+	}
+
+	// the target already has a symlink where the delta adds a directory
+	os.Symlink(outside, filepath.Join(dst, "real"))
+	err = WriteDelta(src, map[string]EntryChange{
+		"real/x": {Type: ChangeTypeAdd, Entry: es["real/x"]},
+	}, delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply(dst, delta); err != ErrUnsafePath {
+		t.Errorf("error %v, want %v", err, ErrUnsafePath)
+	}
+
+	if names, _ := filepath.Glob(filepath.Join(outside, "*")); len(names) != 0 {
+		t.Errorf("written outside the target: %v", names)
+	}
+	if names, _ := filepath.Glob(filepath.Join(dst, stage_prefix+"*")); len(names) != 0 {
+		t.Errorf("staging left: %v", names)
+	}
+}
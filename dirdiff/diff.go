@@ -2,128 +2,221 @@ package dirdiff
 
 import (
 	"bytes"
-	"crypto/md5"
+	"context"
 	"errors"
-	"fmt"
 	"io"
 	"log"
-	"math"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sync"
 )
 
 var ErrNotDir = errors.New("not dirrectory")
 
 type Entry struct {
-	IsSymlink     bool        `json:"is_symlink"`
-	SymlinkTarget string      `json:"symlink_target"` //only for symbolic file
-	Checksum      []byte      `json:"checksum"`
-	Fileinfo      os.FileInfo `json:"fileinfo"`
+	IsSymlink     bool         `json:"is_symlink"`
+	SymlinkTarget string       `json:"symlink_target"` //only for symbolic file
+	Checksum      []byte       `json:"checksum"`
+	ChecksumAlgo  ChecksumAlgo `json:"checksum_algo,omitempty"`
+	Fileinfo      os.FileInfo  `json:"fileinfo"`
 }
 
 const chunk_size int64 = 10 * 1024 * 1024
 
 var ErrReadLess = errors.New("no enough data read")
 
+type WalkConfig struct {
+	Excludes []*FileMatcher
+	// Parallelism is how many files are hashed at once, runtime.NumCPU() by default.
+	Parallelism int
+	// Checksum is the hash of regular files, ChecksumMD5 by default.
+	Checksum ChecksumAlgo
+	// Reuse is a previous result, such as Manifest.Map(), whose checksums are
+	// taken as they are for regular files of unchanged size and mtime.
+	// DiffDirsContext applies it to newdir only.
+	Reuse map[string]Entry
+	// OldReuse is Reuse for the olddir of DiffDirsContext.
+	OldReuse map[string]Entry
+}
+
+type hash_job struct {
+	rel  string
+	path string
+	size int64
+}
+
+// WalkDir returns the entries under rootdir keyed by their path relative to
+// it, with the MD5 checksum of regular files, see WalkConfig.Checksum for
+// other algorithms. It does not change the working directory and can be
+// called from several goroutines at once.
 func WalkDir(rootdir string, excludes []*FileMatcher) (map[string]Entry, error) {
-	cwd, err := os.Getwd()
-	if err != nil {
-		return nil, err
+	return WalkDirContext(context.Background(), rootdir, &WalkConfig{Excludes: excludes})
+}
+
+// WalkDirContext is WalkDir hashing files on cfg.Parallelism goroutines,
+// while the tree is being walked. It stops early with ctx.Err() once ctx is
+// done. A nil cfg is the zero WalkConfig.
+func WalkDirContext(ctx context.Context, rootdir string, cfg *WalkConfig) (map[string]Entry, error) {
+	if cfg == nil {
+		cfg = &WalkConfig{}
 	}
 
-	cwd, err = filepath.Abs(cwd)
+	abs_rootdir, err := filepath.Abs(rootdir)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := os.Chdir(rootdir); err != nil {
+	algo := cfg.Checksum.resolve()
+	if _, err := algo.New(); err != nil {
 		return nil, err
 	}
 
-	es := make(map[string]Entry)
+	parallelism := cfg.Parallelism
+	if parallelism <= 0 {
+		parallelism = runtime.NumCPU()
+	}
+
+	var (
+		mutex sync.Mutex
+		es    = make(map[string]Entry)
+		jobs  = make(chan hash_job, parallelism)
+		wg    sync.WaitGroup
+	)
+
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for job := range jobs {
+				chksum, err := file_checksum(ctx, algo, job.path, job.size)
+
+				mutex.Lock()
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Printf("[dirdiff] %s error: %v", job.rel, err)
+					}
+					delete(es, job.rel)
+				} else {
+					e := es[job.rel]
+					e.Checksum = chksum
+					e.ChecksumAlgo = algo
+					es[job.rel] = e
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
 
-	err = filepath.Walk(".", func(pth string, info os.FileInfo, err error) error {
+	err = filepath.Walk(abs_rootdir, func(epath string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if pth == "." {
+		if epath == abs_rootdir {
 			return nil
 		}
 
-		for _, matcher := range excludes {
-			if matched, err := matcher.Match(pth); err != nil {
+		rel, err := filepath.Rel(abs_rootdir, epath)
+		if err != nil {
+			return err
+		}
+
+		for _, matcher := range cfg.Excludes {
+			if matched, err := matcher.Match(rel); err != nil {
 				return err
 			} else if matched {
 				return nil
 			}
 		}
 
-		var chksum []byte
-
-		is_dir := info.IsDir()
-		filesize := info.Size()
-
 		e := Entry{
-			Checksum: chksum,
 			Fileinfo: info,
 		}
-
 		if info.Mode()&os.ModeSymlink == os.ModeSymlink {
 			e.IsSymlink = true
-			e.SymlinkTarget, err = os.Readlink(pth)
+			e.SymlinkTarget, err = os.Readlink(epath)
 			if err != nil {
-				log.Printf("[dirdiff] %s %v", pth, err)
+				log.Printf("[dirdiff] %s %v", rel, err)
 			}
 		}
 
+		if prev, ok := cfg.Reuse[rel]; ok && reusable(prev, info, algo) {
+			e.Checksum = prev.Checksum
+			e.ChecksumAlgo = algo
+		}
+
+		mutex.Lock()
+		es[rel] = e
+		mutex.Unlock()
+
 		//TODO: skip device or socket file
-		if !is_dir && !e.IsSymlink {
-			fp, err := os.OpenFile(pth, os.O_RDONLY, 0666)
-			if err != nil {
-				log.Printf("[dirdiff] %s error: %v", pth, err)
-				return nil
+		if !info.IsDir() && !e.IsSymlink && e.Checksum == nil {
+			select {
+			case jobs <- hash_job{rel, epath, info.Size()}:
+			case <-ctx.Done():
+				return ctx.Err()
 			}
+		}
+		return nil
+	})
 
-			h := md5.New()
-			chunks := int64(math.Floor(float64(filesize) / float64(chunk_size)))
+	close(jobs)
+	wg.Wait()
 
-			var i int64
-			for i = 0; i < chunks; i++ {
-				n, err := io.CopyN(h, fp, chunk_size)
-				if err != nil {
-					fp.Close()
-					log.Printf("[dirdiff] %s error: %v", pth, err)
-					return nil
-				} else if n != chunk_size {
-					log.Printf("[dirdiff] %s error: %v", pth, ErrReadLess)
-					return nil
-				}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return es, nil
+}
 
-			}
+// reusable tells whether the checksum of prev still holds for a file of info.
+func reusable(prev Entry, info os.FileInfo, algo ChecksumAlgo) bool {
+	if prev.Checksum == nil || prev.Fileinfo == nil || !same_checksum(prev.ChecksumAlgo, algo) {
+		return false
+	}
+	return info.Mode().IsRegular() && prev.Fileinfo.Mode().IsRegular() &&
+		prev.Fileinfo.Size() == info.Size() && prev.Fileinfo.ModTime().Equal(info.ModTime())
+}
 
-			left := filesize - chunks*chunk_size
-			if left > 0 {
-				n, err := io.CopyN(h, fp, left)
-				if err != nil {
-					fp.Close()
-					log.Printf("[dirdiff] %s error: %v", pth, err)
-					return nil
-				} else if n != left {
-					log.Printf("[dirdiff] %s error: %v", pth, ErrReadLess)
-					return nil
-				}
-			}
+// file_checksum returns the algo checksum of the first size bytes of file,
+// read by chunks of chunk_size, checking ctx between them.
+func file_checksum(ctx context.Context, algo ChecksumAlgo, file string, size int64) ([]byte, error) {
+	h, err := algo.New()
+	if err != nil {
+		return nil, err
+	}
+
+	fp, err := os.OpenFile(file, os.O_RDONLY, 0666)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
 
-			e.Checksum = h.Sum(nil)
+	for left := size; left > 0; {
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
 
-		es[pth] = e
-		return nil
-	})
+		n := left
+		if n > chunk_size {
+			n = chunk_size
+		}
 
-	err = os.Chdir(cwd)
-	return es, err
+		read, err := io.CopyN(h, fp, n)
+		if err != nil {
+			return nil, err
+		} else if read != n {
+			return nil, ErrReadLess
+		}
+		left -= n
+	}
+
+	return h.Sum(nil), nil
 }
 
 type ChangeType uint8
@@ -152,6 +245,8 @@ func (ct ChangeType) String() string {
 type EntryChange struct {
 	Type  ChangeType `json:"type"`
 	Entry Entry      `json:"entry"`
+	// Old is the replaced entry of ChangeTypeModify and ChangeTypeMode.
+	Old *Entry `json:"old,omitempty"`
 }
 
 type FileChange struct {
@@ -166,30 +261,55 @@ func DiffDirs(olddir string, newdir string, excludes []string) (map[string]Entry
 		matchers = append(matchers, NewFileMatcher(s))
 	}
 
-	oldents, err := WalkDir(olddir, matchers)
+	return DiffDirsContext(context.Background(), olddir, newdir, &WalkConfig{Excludes: matchers})
+}
+
+// DiffDirsContext is DiffDirs walking both directories with WalkDirContext,
+// olddir with cfg.OldReuse in place of cfg.Reuse.
+func DiffDirsContext(ctx context.Context, olddir string, newdir string, cfg *WalkConfig) (map[string]EntryChange, error) {
+	if cfg == nil {
+		cfg = &WalkConfig{}
+	}
+
+	oldcfg := *cfg
+	oldcfg.Reuse = cfg.OldReuse
+
+	oldents, err := WalkDirContext(ctx, olddir, &oldcfg)
 	if err != nil {
 		return nil, err
 	}
 
-	newents, err := WalkDir(newdir, matchers)
+	newents, err := WalkDirContext(ctx, newdir, cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	return DiffEntries(oldents, newents)
+}
+
+// DiffEntries compares two results of WalkDir, refusing with ErrMixedChecksum
+// to compare files hashed with different algorithms.
+func DiffEntries(oldents, newents map[string]Entry) (map[string]EntryChange, error) {
 	ret := make(map[string]EntryChange)
 
 	for pth, olde := range oldents {
+		olde := olde
 		if newe, ok := newents[pth]; ok {
+			if olde.Checksum != nil && newe.Checksum != nil && !same_checksum(olde.ChecksumAlgo, newe.ChecksumAlgo) {
+				return nil, ErrMixedChecksum
+			}
+
 			if olde.Fileinfo.Size() != newe.Fileinfo.Size() || !bytes.Equal(olde.Checksum, newe.Checksum) {
-				fmt.Printf("%s %x %x\n", pth, newe.Checksum, olde.Checksum)
 				ret[pth] = EntryChange{
 					Type:  ChangeTypeModify,
 					Entry: newe,
+					Old:   &olde,
 				}
 			} else if olde.Fileinfo.Mode() != newe.Fileinfo.Mode() {
 				ret[pth] = EntryChange{
 					Type:  ChangeTypeMode,
 					Entry: newe,
+					Old:   &olde,
 				}
 			}
 		} else {
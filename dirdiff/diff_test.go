@@ -0,0 +1,238 @@
+package dirdiff
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// make_tree creates files under dir, their content being their name
+// followed by suffix.
+func make_tree(t *testing.T, dir string, suffix string, files ...string) {
+	for _, f := range files {
+		name := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(f+suffix), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// tar_names returns the sorted member names of the tar file, gzipped or not.
+func tar_names(file string, gz bool) ([]string, error) {
+	fp, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	var r io.Reader = fp
+	if gz {
+		gzr, err := gzip.NewReader(fp)
+		if err != nil {
+			return nil, err
+		}
+		r = gzr
+	}
+
+	var names []string
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, hdr.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func TestDiffDirs(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "dirdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	olddir, newdir := filepath.Join(tmp, "old"), filepath.Join(tmp, "new")
+	make_tree(t, olddir, "", "same", "modified", "deleted", "mode", "logs/x.log")
+	make_tree(t, newdir, "", "same", "added", "mode", "sub/added", "logs/y.log")
+	make_tree(t, newdir, "!", "modified")
+	os.Chmod(filepath.Join(newdir, "mode"), 0600)
+
+	changes, err := DiffDirs(olddir, newdir, []string{"/logs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for f, c := range changes {
+		got = append(got, f+":"+c.Type.String())
+	}
+	sort.Strings(got)
+	want := "added:add deleted:delete mode:filemode modified:modify sub/added:add sub:add"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+
+	dst := filepath.Join(tmp, "patch")
+	if errs := Tar(newdir, changes, dst); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	names, err := tar_names(dst+".tar", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(names, " "); got != "added modified sub sub/added" {
+		t.Errorf("tar entries: %s", got)
+	}
+}
+
+// TestConcurrent runs the operations on different trees at once, each
+// must only see its own tree.
+func TestConcurrent(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "dirdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	cwd, _ := os.Getwd()
+
+	const n = 8
+	for i := 0; i < n; i++ {
+		make_tree(t, filepath.Join(tmp, fmt.Sprint(i)), "", fmt.Sprintf("f%d", i), fmt.Sprintf("d%d/g%d", i, i))
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			dir := filepath.Join(tmp, fmt.Sprint(i))
+			want := fmt.Sprintf("d%d d%d/g%d f%d", i, i, i, i)
+
+			es, err := WalkDir(dir, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			var names []string
+			for f := range es {
+				names = append(names, f)
+			}
+			sort.Strings(names)
+			if got := strings.Join(names, " "); got != want {
+				errs <- fmt.Errorf("WalkDir %d: got %s, want %s", i, got, want)
+			}
+
+			tarfile := filepath.Join(tmp, fmt.Sprintf("%d.tar", i))
+			if err := TarDir(dir, tarfile); err != nil {
+				errs <- err
+			} else if names, err := tar_names(tarfile, false); err != nil {
+				errs <- err
+			} else if got := strings.Join(names, " "); got != want {
+				errs <- fmt.Errorf("TarDir %d: got %s, want %s", i, got, want)
+			}
+
+			tgzfile := filepath.Join(tmp, fmt.Sprintf("%d.tgz", i))
+			if err := TgzDir(dir, tgzfile); err != nil {
+				errs <- err
+			} else if names, err := tar_names(tgzfile, true); err != nil {
+				errs <- err
+			} else if got := strings.Join(names, " "); got != want {
+				errs <- fmt.Errorf("TgzDir %d: got %s, want %s", i, got, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if now, _ := os.Getwd(); now != cwd {
+		t.Errorf("working directory changed to %s", now)
+	}
+}
+
+func TestWalkDirParallelism(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "dirdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	var files []string
+	for i := 0; i < 50; i++ {
+		files = append(files, fmt.Sprintf("d%d/f%d", i%5, i))
+	}
+	make_tree(t, tmp, "", files...)
+
+	serial, err := WalkDirContext(context.Background(), tmp, &WalkConfig{Parallelism: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(serial) != 55 {
+		t.Fatalf("%d entries, want 55", len(serial))
+	}
+
+	parallel, err := WalkDirContext(context.Background(), tmp, &WalkConfig{Parallelism: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parallel) != len(serial) {
+		t.Fatalf("%d entries, want %d", len(parallel), len(serial))
+	}
+
+	for rel, e := range serial {
+		pe, ok := parallel[rel]
+		if !ok {
+			t.Fatalf("%s missing", rel)
+		}
+		if !bytes.Equal(pe.Checksum, e.Checksum) {
+			t.Errorf("%s checksum %x, want %x", rel, pe.Checksum, e.Checksum)
+		}
+		if !e.Fileinfo.IsDir() && len(e.Checksum) == 0 {
+			t.Errorf("%s has no checksum", rel)
+		}
+	}
+}
+
+func TestWalkDirCanceled(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "dirdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	make_tree(t, tmp, "", "a", "b", "c/d")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := WalkDirContext(ctx, tmp, &WalkConfig{}); err != context.Canceled {
+		t.Fatalf("error %v, want %v", err, context.Canceled)
+	}
+	if _, err := DiffDirsContext(ctx, tmp, tmp, &WalkConfig{}); err != context.Canceled {
+		t.Fatalf("error %v, want %v", err, context.Canceled)
+	}
+}
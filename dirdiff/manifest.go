@@ -0,0 +1,180 @@
+package dirdiff
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const manifest_version = 1
+
+var ErrManifestVersion = errors.New("unsupported manifest version")
+
+// Manifest is the persisted result of a walk, so that a directory can later
+// be diffed against it without the original tree.
+type Manifest struct {
+	Version  int             `json:"version"`
+	Checksum ChecksumAlgo    `json:"checksum"`
+	Created  time.Time       `json:"created"`
+	Entries  []ManifestEntry `json:"entries"`
+}
+
+// ManifestEntry is one Entry of a Manifest, Entries being sorted by Path.
+type ManifestEntry struct {
+	Path          string      `json:"path"`
+	Size          int64       `json:"size"`
+	Mode          os.FileMode `json:"mode"`
+	ModTime       time.Time   `json:"mtime"`
+	Checksum      []byte      `json:"checksum,omitempty"`
+	SymlinkTarget string      `json:"symlink_target,omitempty"`
+}
+
+// NewManifest returns the manifest of es, a result of WalkDir, refusing with
+// ErrMixedChecksum entries hashed with different algorithms.
+func NewManifest(es map[string]Entry) (*Manifest, error) {
+	m := &Manifest{
+		Version: manifest_version,
+		Created: time.Now(),
+		Entries: make([]ManifestEntry, 0, len(es)),
+	}
+
+	var algo ChecksumAlgo
+	for p, e := range es {
+		if e.Checksum != nil {
+			if algo == "" {
+				algo = e.ChecksumAlgo.resolve()
+			} else if !same_checksum(algo, e.ChecksumAlgo) {
+				return nil, ErrMixedChecksum
+			}
+		}
+
+		m.Entries = append(m.Entries, manifest_entry(p, e))
+	}
+	m.Checksum = algo.resolve()
+
+	sort.Slice(m.Entries, func(i, j int) bool {
+		return m.Entries[i].Path < m.Entries[j].Path
+	})
+	return m, nil
+}
+
+func manifest_entry(p string, e Entry) ManifestEntry {
+	return ManifestEntry{
+		Path:          filepath.ToSlash(p),
+		Size:          e.Fileinfo.Size(),
+		Mode:          e.Fileinfo.Mode(),
+		ModTime:       e.Fileinfo.ModTime(),
+		Checksum:      e.Checksum,
+		SymlinkTarget: e.SymlinkTarget,
+	}
+}
+
+// Map returns the entries of m keyed by path, as WalkDir does.
+func (m *Manifest) Map() map[string]Entry {
+	es := make(map[string]Entry, len(m.Entries))
+	for _, me := range m.Entries {
+		e := Entry{
+			IsSymlink:     me.Mode&os.ModeSymlink == os.ModeSymlink,
+			SymlinkTarget: me.SymlinkTarget,
+			Checksum:      me.Checksum,
+			Fileinfo:      manifest_fileinfo{me},
+		}
+		if me.Checksum != nil {
+			e.ChecksumAlgo = m.Checksum
+		}
+		es[filepath.FromSlash(me.Path)] = e
+	}
+	return es
+}
+
+// WriteManifest saves m as JSON to file, replacing it atomically.
+func WriteManifest(file string, m *Manifest) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// ReadManifest loads a manifest saved by WriteManifest.
+func ReadManifest(file string) (*Manifest, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	m := new(Manifest)
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	if m.Version != manifest_version {
+		return nil, ErrManifestVersion
+	}
+	m.Checksum = m.Checksum.resolve()
+	return m, nil
+}
+
+// DiffManifest compares the live directory dir to m as DiffDirs compares
+// two directories, m being the old side. Files are hashed with the
+// algorithm of m, cfg.Checksum must be empty or the same. Setting
+// cfg.Reuse to m.Map() skips hashing files whose size and mtime match m.
+// A nil cfg is the zero WalkConfig.
+func DiffManifest(ctx context.Context, m *Manifest, dir string, cfg *WalkConfig) (map[string]EntryChange, error) {
+	if cfg == nil {
+		cfg = &WalkConfig{}
+	}
+
+	if cfg.Checksum != "" && !same_checksum(cfg.Checksum, m.Checksum) {
+		return nil, ErrMixedChecksum
+	}
+
+	wcfg := *cfg
+	wcfg.Checksum = m.Checksum
+
+	newents, err := WalkDirContext(ctx, dir, &wcfg)
+	if err != nil {
+		return nil, err
+	}
+	return DiffEntries(m.Map(), newents)
+}
+
+// manifest_fileinfo is the os.FileInfo of a ManifestEntry.
+type manifest_fileinfo struct {
+	e ManifestEntry
+}
+
+func (fi manifest_fileinfo) Name() string       { return path.Base(fi.e.Path) }
+func (fi manifest_fileinfo) Size() int64        { return fi.e.Size }
+func (fi manifest_fileinfo) Mode() os.FileMode  { return fi.e.Mode }
+func (fi manifest_fileinfo) ModTime() time.Time { return fi.e.ModTime }
+func (fi manifest_fileinfo) IsDir() bool        { return fi.e.Mode.IsDir() }
+func (fi manifest_fileinfo) Sys() interface{}   { return nil }
@@ -0,0 +1,144 @@
+package dirdiff
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManifest(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "dirdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	dir := filepath.Join(tmp, "dir")
+	make_tree(t, dir, "", "same", "modified", "deleted", "touched", "sub/mode")
+	os.Symlink("same", filepath.Join(dir, "link"))
+
+	es, err := WalkDirContext(context.Background(), dir, &WalkConfig{Checksum: ChecksumSHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewManifest(es)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(tmp, "manifest.json")
+	if err := WriteManifest(file, m); err != nil {
+		t.Fatal(err)
+	}
+	if m, err = ReadManifest(file); err != nil {
+		t.Fatal(err)
+	}
+	if m.Checksum != ChecksumSHA256 || len(m.Entries) != len(es) {
+		t.Fatalf("manifest %s with %d entries, want %s with %d", m.Checksum, len(m.Entries), ChecksumSHA256, len(es))
+	}
+
+	if changes, err := DiffManifest(context.Background(), m, dir, nil); err != nil || len(changes) != 0 {
+		t.Fatalf("changes %v, error %v", changes, err)
+	}
+
+	// same size and mtime, only seen when hashing
+	touched := filepath.Join(dir, "touched")
+	finfo, _ := os.Stat(touched)
+	ioutil.WriteFile(touched, []byte("TOUCHED"), 0644)
+	os.Chtimes(touched, finfo.ModTime(), finfo.ModTime())
+
+	make_tree(t, dir, "!", "modified", "added")
+	os.Remove(filepath.Join(dir, "deleted"))
+	os.Chmod(filepath.Join(dir, "sub/mode"), 0600)
+
+	want := map[string]ChangeType{
+		"modified": ChangeTypeModify,
+		"added":    ChangeTypeAdd,
+		"deleted":  ChangeTypeDelete,
+		"sub/mode": ChangeTypeMode,
+	}
+
+	check := func(cfg *WalkConfig, want map[string]ChangeType) {
+		changes, err := DiffManifest(context.Background(), m, dir, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != len(want) {
+			t.Errorf("changes %v, want %v", changes, want)
+		}
+		for f, ct := range want {
+			if changes[f].Type != ct {
+				t.Errorf("%s: %s, want %s", f, changes[f].Type, ct)
+			}
+		}
+	}
+
+	check(&WalkConfig{Reuse: m.Map()}, want)
+
+	want["touched"] = ChangeTypeModify
+	check(&WalkConfig{}, want)
+
+	if _, err := DiffManifest(context.Background(), m, dir, &WalkConfig{Checksum: ChecksumMD5}); err != ErrMixedChecksum {
+		t.Errorf("error %v, want %v", err, ErrMixedChecksum)
+	}
+}
+
+// TestDiffDirsReuse checks that the reuse source of one tree is not applied
+// to the other, whose file has the same size and mtime but another content.
+func TestDiffDirsReuse(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "dirdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	olddir, newdir := filepath.Join(tmp, "old"), filepath.Join(tmp, "new")
+	make_tree(t, olddir, "", "f")
+	ioutil.WriteFile(filepath.Join(olddir, "f"), []byte("old"), 0644)
+	make_tree(t, newdir, "", "f")
+	ioutil.WriteFile(filepath.Join(newdir, "f"), []byte("new"), 0644)
+	mtime := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(olddir, "f"), mtime, mtime)
+	os.Chtimes(filepath.Join(newdir, "f"), mtime, mtime)
+
+	oldents, err := WalkDir(olddir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newents, err := WalkDir(newdir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, cfg := range map[string]*WalkConfig{
+		"Reuse":          {Reuse: newents},
+		"Reuse+OldReuse": {Reuse: newents, OldReuse: oldents},
+	} {
+		changes, err := DiffDirsContext(context.Background(), olddir, newdir, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 1 || changes["f"].Type != ChangeTypeModify {
+			t.Errorf("%s: changes %v, want f modified", name, changes)
+		}
+	}
+}
+
+func TestManifestVersion(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "dirdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	file := filepath.Join(tmp, "manifest.json")
+	if err := WriteManifest(file, &Manifest{Version: 2, Created: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadManifest(file); err != ErrManifestVersion {
+		t.Errorf("error %v, want %v", err, ErrManifestVersion)
+	}
+}
@@ -10,45 +10,32 @@ import (
 	"path/filepath"
 )
 
+// Tar writes the added and modified entries of fc, read under rootdir, to
+// dst_path.tar and their checksums to a sidecar named after the algorithm,
+// such as dst_path.md5sum or dst_path.sha256sum. It does not change the
+// working directory. Deletions and mode changes are left out, WriteDelta
+// records them for Apply.
 func Tar(rootdir string, fc map[string]EntryChange, dst_path string) (errs []error) {
-
-	cwd, err := os.Getwd()
-	if err != nil {
-		errs = append(errs, err)
-		return
-	}
-
-	cwd, err = filepath.Abs(cwd)
-	if err != nil {
-		errs = append(errs, err)
-		return
-	}
-
 	dst, err := filepath.Abs(dst_path)
 	if err != nil {
 		errs = append(errs, err)
 		return
 	}
 
-	md5file, err := os.OpenFile(dst+".md5sum", os.O_WRONLY|os.O_CREATE, 0644)
+	algo, err := changes_checksum(fc)
 	if err != nil {
 		errs = append(errs, err)
 		return
 	}
-	defer md5file.Close()
-
-	if err := os.Chdir(rootdir); err != nil {
-		errs = append(errs, err)
-		return
-	}
 
-	tarfile, err := os.OpenFile(dst+".tar", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	sumfile, err := os.OpenFile(SumFile(dst, algo), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
 		errs = append(errs, err)
 		return
 	}
+	defer sumfile.Close()
 
-	err = tarfile.Truncate(0)
+	tarfile, err := os.OpenFile(dst+".tar", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
 		errs = append(errs, err)
 		return
@@ -57,10 +44,6 @@ func Tar(rootdir string, fc map[string]EntryChange, dst_path string) (errs []err
 
 	tw := tar.NewWriter(tarfile)
 	for file, e := range fc {
-		if e.Type == ChangeTypeDelete {
-			continue
-		}
-
 		if e.Type != ChangeTypeAdd && e.Type != ChangeTypeModify {
 			continue
 		}
@@ -81,45 +64,63 @@ func Tar(rootdir string, fc map[string]EntryChange, dst_path string) (errs []err
 		}
 
 		if hdr.Typeflag != tar.TypeDir && !e.Entry.IsSymlink {
-			md5file.WriteString(fmt.Sprintf("%x  %s\n", e.Entry.Checksum, file))
+			sumfile.WriteString(fmt.Sprintf("%x  %s\n", e.Entry.Checksum, file))
 		}
 
 		if hdr.Typeflag == tar.TypeDir || hdr.Typeflag == tar.TypeSymlink {
 			continue
 		}
 
-		fp, err := os.OpenFile(file, os.O_RDONLY, 0644)
-		if err != nil {
+		if err := copy_file(tw, filepath.Join(rootdir, file), e.Entry.Fileinfo.Size()); err != nil {
 			log.Printf("[dirdiff] Tar %s error: %v", file, err)
 			errs = append(errs, err)
-			continue
 		}
+	}
 
-		n, err := io.CopyN(tw, fp, e.Entry.Fileinfo.Size())
-		if n != e.Entry.Fileinfo.Size() {
-			fp.Close()
-			log.Printf("[dirdiff] Tar %s error: %v", file, ErrReadLess)
-			errs = append(errs, err)
+	if err := tw.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	return
+}
+
+// SumFile returns the name of the checksum sidecar of dst_path written by Tar.
+func SumFile(dst_path string, algo ChecksumAlgo) string {
+	return dst_path + "." + string(algo.resolve()) + "sum"
+}
+
+// changes_checksum returns the algorithm the files of fc were hashed with,
+// ErrMixedChecksum if there are several.
+func changes_checksum(fc map[string]EntryChange) (ChecksumAlgo, error) {
+	var algo ChecksumAlgo
+	for _, e := range fc {
+		if e.Type != ChangeTypeAdd && e.Type != ChangeTypeModify || e.Entry.Checksum == nil {
 			continue
 		}
-		if err != nil {
-			fp.Close()
-			log.Printf("[dirdiff] Tar %s error: %v", file, err)
-			errs = append(errs, err)
-			continue
+		if algo == "" {
+			algo = e.Entry.ChecksumAlgo.resolve()
+		} else if !same_checksum(algo, e.Entry.ChecksumAlgo) {
+			return "", ErrMixedChecksum
 		}
-
-		fp.Close()
-
 	}
+	return algo.resolve(), nil
+}
 
-	tw.Close()
+// copy_file copies the first size bytes of file to w.
+func copy_file(w io.Writer, file string, size int64) error {
+	fp, err := os.OpenFile(file, os.O_RDONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
 
-	err = os.Chdir(cwd)
+	n, err := io.CopyN(w, fp, size)
 	if err != nil {
-		errs = append(errs, err)
+		return err
 	}
-	return
+	if n != size {
+		return ErrReadLess
+	}
+	return nil
 }
 
 func getFileType(mod os.FileMode) byte {
@@ -141,125 +142,65 @@ func getFileType(mod os.FileMode) byte {
 	return tar.TypeReg
 }
 
+// TgzDir writes srcdir as a gzipped tar archive to target_name, with paths
+// relative to srcdir. It does not change the working directory.
 func TgzDir(srcdir string, target_name string) error {
-	cwd, err := os.Getwd()
+	tgzfile, err := os.OpenFile(target_name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
 		return err
 	}
+	defer tgzfile.Close()
 
-	cwd, err = filepath.Abs(cwd)
+	gzfp, err := gzip.NewWriterLevel(tgzfile, gzip.DefaultCompression)
 	if err != nil {
 		return err
 	}
 
-	if err := os.Chdir(srcdir); err != nil {
-		return err
-	}
-
-	// es := make(map[string]Entry)
-
-	tgzfile, err := os.OpenFile(target_name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
+	if err := tar_dir(srcdir, gzfp); err != nil {
+		gzfp.Close()
 		return err
 	}
-	defer tgzfile.Close()
-
-	gzfp, err := gzip.NewWriterLevel(tgzfile, gzip.DefaultCompression)
-	if err != nil {
+	if err := gzfp.Close(); err != nil {
 		return err
 	}
-	defer gzfp.Close()
-
-	tw := tar.NewWriter(gzfp)
-
-	err = filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
-		if path == "." {
-			return nil
-		}
-
-		symlink := ""
-		if info.Mode()&os.ModeSymlink == os.ModeSymlink {
-			if symlink, err = os.Readlink(path); err != nil {
-				return err
-			}
-		}
-
-		hdr, err := tar.FileInfoHeader(info, symlink)
-		if err != nil {
-			return err
-		}
-		hdr.Name = path
-
-		if err = tw.WriteHeader(hdr); err != nil {
-			return err
-		}
-
-		switch hdr.Typeflag {
-		case tar.TypeDir, tar.TypeSymlink:
-			return nil
-		case tar.TypeReg, tar.TypeRegA:
-		default:
-			return fmt.Errorf("Unknown tar file type: %c", hdr.Typeflag)
-		}
-
-		fp, err := os.OpenFile(path, os.O_RDONLY, 0644)
-		if err != nil {
-			return err
-		}
-
-		n, err := io.CopyN(tw, fp, info.Size())
-		if err != nil {
-			fp.Close()
-			return err
-		}
-
-		if n != info.Size() {
-			fp.Close()
-			return ErrReadLess
-		}
-
-		fp.Close()
-		return nil
-	})
-
-	tw.Close()
-	return os.Chdir(cwd)
+	return tgzfile.Close()
 }
 
+// TarDir writes srcdir as a tar archive to target_name, with paths relative
+// to srcdir. It does not change the working directory.
 func TarDir(srcdir string, target_name string) error {
-	cwd, err := os.Getwd()
-	if err != nil {
-		return err
-	}
-
-	cwd, err = filepath.Abs(cwd)
+	tarfile, err := os.OpenFile(target_name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
 		return err
 	}
+	defer tarfile.Close()
 
-	if err := os.Chdir(srcdir); err != nil {
+	if err := tar_dir(srcdir, tarfile); err != nil {
 		return err
 	}
+	return tarfile.Close()
+}
 
-	// es := make(map[string]Entry)
-
-	tarfile, err := os.OpenFile(target_name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+func tar_dir(srcdir string, w io.Writer) error {
+	abs_srcdir, err := filepath.Abs(srcdir)
 	if err != nil {
 		return err
 	}
-	defer tarfile.Close()
 
-	tw := tar.NewWriter(tarfile)
-	defer tw.Close()
+	tw := tar.NewWriter(w)
+
+	err = filepath.Walk(abs_srcdir, func(epath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
 
-	err = filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
-		if path == "." {
+		if epath == abs_srcdir {
 			return nil
 		}
 
 		symlink := ""
 		if info.Mode()&os.ModeSymlink == os.ModeSymlink {
-			if symlink, err = os.Readlink(path); err != nil {
+			if symlink, err = os.Readlink(epath); err != nil {
 				return err
 			}
 		}
@@ -268,7 +209,10 @@ func TarDir(srcdir string, target_name string) error {
 		if err != nil {
 			return err
 		}
-		hdr.Name = path
+
+		if hdr.Name, err = filepath.Rel(abs_srcdir, epath); err != nil {
+			return err
+		}
 
 		if err = tw.WriteHeader(hdr); err != nil {
 			return err
@@ -282,25 +226,11 @@ func TarDir(srcdir string, target_name string) error {
 			return fmt.Errorf("Unknown tar file type: %c", hdr.Typeflag)
 		}
 
-		fp, err := os.OpenFile(path, os.O_RDONLY, 0644)
-		if err != nil {
-			return err
-		}
-
-		n, err := io.CopyN(tw, fp, info.Size())
-		if err != nil {
-			fp.Close()
-			return err
-		}
-
-		if n != info.Size() {
-			fp.Close()
-			return ErrReadLess
-		}
-
-		fp.Close()
-		return nil
+		return copy_file(tw, epath, info.Size())
 	})
 
-	return os.Chdir(cwd)
+	if err != nil {
+		return err
+	}
+	return tw.Close()
 }
@@ -0,0 +1,132 @@
+package dirdiff
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+const (
+	xxh_prime1 uint64 = 11400714785074694791
+	xxh_prime2 uint64 = 14029467366897019727
+	xxh_prime3 uint64 = 1609587929392839161
+	xxh_prime4 uint64 = 9650029242287828579
+	xxh_prime5 uint64 = 2870177450012600261
+)
+
+// xxh64 is XXH64 with a zero seed, as a hash.Hash64. Sum appends the digest
+// big endian, as the reference xxhsum prints it.
+type xxh64 struct {
+	v1, v2, v3, v4 uint64
+	total          uint64
+	mem            [32]byte
+	n              int
+}
+
+func new_xxh64() *xxh64 {
+	h := new(xxh64)
+	h.Reset()
+	return h
+}
+
+func (h *xxh64) Reset() {
+	h.v1 = xxh_prime1
+	h.v1 += xxh_prime2
+	h.v2 = xxh_prime2
+	h.v3 = 0
+	h.v4 = 0
+	h.v4 -= xxh_prime1
+	h.total = 0
+	h.n = 0
+}
+
+func (h *xxh64) Size() int      { return 8 }
+func (h *xxh64) BlockSize() int { return 32 }
+
+func (h *xxh64) Write(b []byte) (int, error) {
+	n := len(b)
+	h.total += uint64(n)
+
+	if h.n+len(b) < 32 {
+		h.n += copy(h.mem[h.n:], b)
+		return n, nil
+	}
+
+	if h.n > 0 {
+		c := copy(h.mem[h.n:], b)
+		h.blocks(h.mem[:])
+		b = b[c:]
+		h.n = 0
+	}
+
+	if len(b) >= 32 {
+		l := len(b) &^ 31
+		h.blocks(b[:l])
+		b = b[l:]
+	}
+
+	h.n = copy(h.mem[:], b)
+	return n, nil
+}
+
+func (h *xxh64) blocks(b []byte) {
+	v1, v2, v3, v4 := h.v1, h.v2, h.v3, h.v4
+	for ; len(b) >= 32; b = b[32:] {
+		v1 = xxh_round(v1, binary.LittleEndian.Uint64(b[0:8]))
+		v2 = xxh_round(v2, binary.LittleEndian.Uint64(b[8:16]))
+		v3 = xxh_round(v3, binary.LittleEndian.Uint64(b[16:24]))
+		v4 = xxh_round(v4, binary.LittleEndian.Uint64(b[24:32]))
+	}
+	h.v1, h.v2, h.v3, h.v4 = v1, v2, v3, v4
+}
+
+func (h *xxh64) Sum64() uint64 {
+	var x uint64
+	if h.total >= 32 {
+		x = bits.RotateLeft64(h.v1, 1) + bits.RotateLeft64(h.v2, 7) +
+			bits.RotateLeft64(h.v3, 12) + bits.RotateLeft64(h.v4, 18)
+		x = xxh_merge_round(x, h.v1)
+		x = xxh_merge_round(x, h.v2)
+		x = xxh_merge_round(x, h.v3)
+		x = xxh_merge_round(x, h.v4)
+	} else {
+		x = xxh_prime5
+	}
+	x += h.total
+
+	b := h.mem[:h.n]
+	for ; len(b) >= 8; b = b[8:] {
+		x ^= xxh_round(0, binary.LittleEndian.Uint64(b))
+		x = bits.RotateLeft64(x, 27)*xxh_prime1 + xxh_prime4
+	}
+	if len(b) >= 4 {
+		x ^= uint64(binary.LittleEndian.Uint32(b)) * xxh_prime1
+		x = bits.RotateLeft64(x, 23)*xxh_prime2 + xxh_prime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		x ^= uint64(c) * xxh_prime5
+		x = bits.RotateLeft64(x, 11) * xxh_prime1
+	}
+
+	x ^= x >> 33
+	x *= xxh_prime2
+	x ^= x >> 29
+	x *= xxh_prime3
+	x ^= x >> 32
+	return x
+}
+
+func (h *xxh64) Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint64(b, h.Sum64())
+}
+
+func xxh_round(acc, input uint64) uint64 {
+	acc += input * xxh_prime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxh_prime1
+}
+
+func xxh_merge_round(acc, val uint64) uint64 {
+	acc ^= xxh_round(0, val)
+	return acc*xxh_prime1 + xxh_prime4
+}
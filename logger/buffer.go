@@ -0,0 +1,26 @@
+package logger
+
+import "sync"
+
+// max_pooled_buffer is the largest buffer put back into buffer_pool, so that
+// one huge entry does not pin its memory.
+const max_pooled_buffer = 64 << 10
+
+var buffer_pool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 512)
+		return &b
+	},
+}
+
+func get_buffer() *[]byte {
+	return buffer_pool.Get().(*[]byte)
+}
+
+func put_buffer(b *[]byte) {
+	if cap(*b) > max_pooled_buffer {
+		return
+	}
+	*b = (*b)[:0]
+	buffer_pool.Put(b)
+}
@@ -0,0 +1,118 @@
+package logger
+
+import (
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CallerFormat selects how the caller of an entry is written.
+type CallerFormat uint8
+
+const (
+	// CallerShort writes pkg:file.go:func(..):line.
+	CallerShort CallerFormat = iota
+	// CallerFull writes pkg:/full/path/file.go:func(..):line.
+	CallerFull
+)
+
+type caller_info struct {
+	pkg       string
+	file      string
+	full_file string
+	fnc       string
+	line      int
+
+	short string
+	full  string
+}
+
+func (c *caller_info) String() string {
+	return c.short
+}
+
+func (c *caller_info) format(f CallerFormat) string {
+	if f == CallerFull {
+		return c.full
+	}
+	return c.short
+}
+
+// caller_cache maps a program counter to its caller_info, runtime.FuncForPC
+// and the string handling being done once per call site.
+var caller_cache = struct {
+	sync.RWMutex
+	m map[uintptr]*caller_info
+}{m: make(map[uintptr]*caller_info)}
+
+// get_caller_pc returns the program counter of the caller call_path_number
+// frames up the stack, as if runtime.Caller(call_path_number) were called
+// in get_caller_pc, or 0.
+func get_caller_pc(call_path_number int) uintptr {
+	if call_path_number <= 0 {
+		call_path_number = 3
+	}
+
+	var pcs [1]uintptr
+	if runtime.Callers(call_path_number+1, pcs[:]) == 0 {
+		return 0
+	}
+	return pcs[0]
+}
+
+// get_caller_info returns the caller_info of a program counter returned by
+// runtime.Callers.
+func get_caller_info(pc uintptr) *caller_info {
+	if pc == 0 {
+		return &caller_info{short: "???", full: "???"}
+	}
+
+	caller_cache.RLock()
+	ci, ok := caller_cache.m[pc]
+	caller_cache.RUnlock()
+	if ok {
+		return ci
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	ci = new_caller_info(frame.Function, frame.File, frame.Line)
+
+	caller_cache.Lock()
+	caller_cache.m[pc] = ci
+	caller_cache.Unlock()
+	return ci
+}
+
+func new_caller_info(function, file string, line int) *caller_info {
+	ci := &caller_info{
+		full_file: file,
+		line:      line,
+	}
+	_, ci.file = path.Split(file)
+	ci.pkg, ci.fnc = split_func_name(function)
+
+	suffix := ":" + ci.fnc + "(..):" + strconv.Itoa(line)
+	ci.short = ci.pkg + ":" + ci.file + suffix
+	ci.full = ci.pkg + ":" + ci.full_file + suffix
+	return ci
+}
+
+// split_func_name splits a name such as "github.com/a/b.(*T).M" into its
+// package "github.com/a/b" and function "(*T).M".
+func split_func_name(name string) (string, string) {
+	slash := strings.LastIndexByte(name, '/')
+	dot := strings.IndexByte(name[slash+1:], '.')
+	if dot < 0 {
+		return "", name
+	}
+	dot += slash + 1
+	return name[:dot], name[dot+1:]
+}
+
+// SetCallerFormat chooses between short file names and full paths in the
+// caller info.
+func (c *core) SetCallerFormat(f CallerFormat) {
+	c.caller_format = f
+}
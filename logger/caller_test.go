@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSplitFuncName(t *testing.T) {
+	tests := []struct {
+		name, pkg, fnc string
+	}{
+		{"github.com/stormgbs/gopkg/logger.(*core).Info", "github.com/stormgbs/gopkg/logger", "(*core).Info"},
+		{"github.com/stormgbs/gopkg/logger.TestX.func1", "github.com/stormgbs/gopkg/logger", "TestX.func1"},
+		{"gopkg.in/yaml.v2.Unmarshal", "gopkg.in/yaml", "v2.Unmarshal"},
+		{"main.main", "main", "main"},
+		{"main", "", "main"},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		pkg, fnc := split_func_name(tt.name)
+		if pkg != tt.pkg || fnc != tt.fnc {
+			t.Errorf("split_func_name(%q) = %q, %q, want %q, %q", tt.name, pkg, fnc, tt.pkg, tt.fnc)
+		}
+	}
+}
+
+func log_wrapper(l *SimpleLogger, msg string) {
+	l.Output(1, LevelInfo, "%s", msg)
+}
+
+func TestCallerSkip(t *testing.T) {
+	w := &buffer_closer{}
+	l := NewSimpleLogger(w)
+	l.EnableCallerInfo()
+
+	_, _, line, _ := runtime.Caller(0)
+	log_wrapper(l, "wrapped")
+	l.Output(0, LevelWarn, "direct")
+
+	lines := strings.Split(strings.TrimSpace(w.buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines:\n%s", len(lines), w.buf.Bytes())
+	}
+	for i, s := range lines {
+		want := "caller_test.go:TestCallerSkip(..):" + strconv.Itoa(line+1+i) + "]"
+		if !strings.Contains(s, want) {
+			t.Errorf("line %d: %s, want %s", i, s, want)
+		}
+	}
+
+	w.buf.Reset()
+	l.SetCallerFormat(CallerFull)
+	l.Info("full")
+	if !strings.Contains(w.buf.String(), "/logger/caller_test.go:TestCallerSkip(..):") {
+		t.Errorf("no full path: %s", w.buf.Bytes())
+	}
+}
+
+// BenchmarkCallerInfo measures get_caller_pc and get_caller_info, hitting
+// its cache.
+func BenchmarkCallerInfo(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		get_caller_info(get_caller_pc(1))
+	}
+}
+
+// BenchmarkCallerInfoUncached measures the lookup done before the cache, on
+// every entry.
+func BenchmarkCallerInfoUncached(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		pc, file, line, _ := runtime.Caller(1)
+		new_caller_info(runtime.FuncForPC(pc).Name(), file, line)
+	}
+}
@@ -3,11 +3,7 @@ package logger
 import (
 	"errors"
 	"fmt"
-	"path"
-	"reflect"
-	"runtime"
 	"strings"
-	"unsafe"
 )
 
 type Level uint8
@@ -24,79 +20,88 @@ const (
 	LevelCrit
 )
 
-//Mon Jan 2 15:04:05 -0700 MST 2006
-const default_time_format = "2006/01/02 15:04:05.000"
-
-var ErrEmptyLogWriter = errors.New("empty log writer")
-
-func StringToReadonlySlice(s *string) (b []byte) {
-	pbytes := (*reflect.SliceHeader)(unsafe.Pointer(&b))
-	pstring := (*reflect.StringHeader)(unsafe.Pointer(s))
-	pbytes.Data = pstring.Data
-	pbytes.Len = pstring.Len
-	pbytes.Cap = pstring.Len
-	return
-}
-
-type caller_info struct {
-	pkg  string
-	file string
-	fnc  string
-	line int
-}
-
-func (c caller_info) String() string {
-	return fmt.Sprintf("%s:%s:%s(..):%d", c.pkg, c.file, c.fnc, c.line)
+func (lv Level) String() string {
+	switch lv {
+	case LevelDebug4:
+		return "DEBUG4"
+	case LevelDebug3:
+		return "DEBUG3"
+	case LevelDebug2:
+		return "DEBUG2"
+	case LevelDebug1:
+		return "DEBUG1"
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	case LevelCrit:
+		return "CRITICAL"
+	}
+	return "UNKNOWN"
 }
 
-func get_caller_info(call_path_number int) caller_info {
-	if call_path_number <= 0 {
-		call_path_number = 3
+// lower is String in lower case, without allocating.
+func (lv Level) lower() string {
+	switch lv {
+	case LevelDebug4:
+		return "debug4"
+	case LevelDebug3:
+		return "debug3"
+	case LevelDebug2:
+		return "debug2"
+	case LevelDebug1:
+		return "debug1"
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	case LevelCrit:
+		return "critical"
 	}
+	return "unknown"
+}
 
-	pc, file, line, _ := runtime.Caller(call_path_number)
-	_, fileName := path.Split(file)
-	parts := strings.Split(runtime.FuncForPC(pc).Name(), ".")
-	pl := len(parts)
-	packageName := ""
-	funcName := parts[pl-1]
-
-	if parts[pl-2][0] == '(' {
-		funcName = parts[pl-2] + "." + funcName
-		packageName = strings.Join(parts[0:pl-2], ".")
-	} else {
-		packageName = strings.Join(parts[0:pl-1], ".")
-	}
+//Mon Jan 2 15:04:05 -0700 MST 2006
+const default_time_format = "2006/01/02 15:04:05.000"
 
-	return caller_info{
-		pkg:  packageName,
-		file: fileName,
-		fnc:  funcName,
-		line: line,
-	}
-}
+var ErrEmptyLogWriter = errors.New("empty log writer")
 
-func StringToLogLevel(s string) Level {
+// ParseLevel parses a level name as written by Level.String, case
+// insensitively, plus a few common aliases.
+func ParseLevel(s string) (Level, error) {
 	switch strings.ToLower(s) {
 	case "debug4":
-		return LevelDebug4
+		return LevelDebug4, nil
 	case "debug3":
-		return LevelDebug3
+		return LevelDebug3, nil
 	case "debug2":
-		return LevelDebug2
+		return LevelDebug2, nil
 	case "debug1":
-		return LevelDebug1
+		return LevelDebug1, nil
 	case "debug":
-		return LevelDebug
+		return LevelDebug, nil
 	case "info", "information":
-		return LevelInfo
-	case "warn, warning":
-		return LevelWarn
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
 	case "err", "error":
-		return LevelError
+		return LevelError, nil
 	case "cri", "crit", "critical":
-		return LevelCrit
-	default:
-		return LevelDebug
+		return LevelCrit, nil
 	}
+	return LevelDebug, fmt.Errorf("unknown log level %q", s)
+}
+
+// StringToLogLevel is like ParseLevel, it returns LevelDebug for unknown names.
+func StringToLogLevel(s string) Level {
+	lv, _ := ParseLevel(s)
+	return lv
 }
@@ -0,0 +1,158 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+const (
+	default_caller_width = 40
+	level_width          = 8
+)
+
+const (
+	color_reset = "\x1b[0m"
+	color_dim   = "\x1b[2m"
+)
+
+// ConsoleEncoder writes entries for people reading a terminal: the level
+// and caller are padded into columns and the level is colored.
+//
+//	15:04:05.000 INFO     pkg:file.go:func(..):12               {name} msg key=value
+type ConsoleEncoder struct {
+	// Color turns on ANSI colors.
+	Color bool
+	// CallerWidth is the width of the caller column, 40 by default. Longer
+	// callers are cut from the left.
+	CallerWidth int
+}
+
+// NewConsoleEncoder returns a colored ConsoleEncoder if f is a terminal,
+// without colors if the NO_COLOR environment variable is set as well, and a
+// plain TextEncoder if f is not a terminal.
+func NewConsoleEncoder(f *os.File) Encoder {
+	if !is_terminal(f) {
+		return TextEncoder{}
+	}
+	return ConsoleEncoder{Color: os.Getenv("NO_COLOR") == ""}
+}
+
+func is_terminal(f *os.File) bool {
+	return f != nil && term.IsTerminal(int(f.Fd()))
+}
+
+// console_auto is the Encoder of StringToEncoder("console"). The loggers
+// and WriterSink replace it by NewConsoleEncoder of the file they write to,
+// elsewhere it encodes as TextEncoder.
+type console_auto struct {
+	TextEncoder
+}
+
+// resolve_encoder returns enc for entries written to w.
+func resolve_encoder(enc Encoder, w io.Writer) Encoder {
+	if _, ok := enc.(console_auto); !ok {
+		return enc
+	}
+	f, _ := w.(*os.File)
+	return NewConsoleEncoder(f)
+}
+
+func level_color(lv Level) string {
+	switch {
+	case lv < LevelDebug:
+		return "\x1b[90m"
+	case lv == LevelDebug:
+		return "\x1b[36m"
+	case lv == LevelInfo:
+		return "\x1b[32m"
+	case lv == LevelWarn:
+		return "\x1b[33m"
+	case lv == LevelError:
+		return "\x1b[31m"
+	}
+	return "\x1b[1;31m"
+}
+
+func (enc ConsoleEncoder) Encode(e *Entry) []byte {
+	return enc.AppendEntry(make([]byte, 0, 96+len(e.Caller)+len(e.Message)), e)
+}
+
+func (enc ConsoleEncoder) AppendEntry(b []byte, e *Entry) []byte {
+	b = e.Time.AppendFormat(b, e.TimeFormat)
+	b = append(b, ' ')
+
+	level := e.Level.String()
+	if enc.Color {
+		b = append(b, level_color(e.Level)...)
+		b = append(b, level...)
+		b = append(b, color_reset...)
+	} else {
+		b = append(b, level...)
+	}
+	b = append_padding(b, level_width-len(level)+1)
+
+	if e.System != "" {
+		b = append(b, '|')
+		b = append(b, e.System...)
+		b = append(b, "| "...)
+	}
+
+	if e.Caller != "" {
+		width := enc.CallerWidth
+		if width <= 0 {
+			width = default_caller_width
+		}
+
+		caller := e.Caller
+		if n := utf8.RuneCountInString(caller); n > width {
+			// keep the end, marked by ".." when there is room for more
+			if width > 2 {
+				caller = ".." + string([]rune(caller)[n-width+2:])
+			} else {
+				caller = string([]rune(caller)[n-width:])
+			}
+		}
+
+		if enc.Color {
+			b = append(b, color_dim...)
+			b = append(b, caller...)
+			b = append(b, color_reset...)
+		} else {
+			b = append(b, caller...)
+		}
+		b = append_padding(b, width-utf8.RuneCountInString(caller)+1)
+	}
+
+	if e.Name != "" {
+		b = append(b, '{')
+		b = append(b, e.Name...)
+		b = append(b, "} "...)
+	}
+	b = append(b, e.Message...)
+
+	for _, f := range e.Fields {
+		b = append(b, ' ')
+		if enc.Color {
+			b = append(b, color_dim...)
+			b = append(b, f.Key...)
+			b = append(b, '=')
+			b = append(b, color_reset...)
+		} else {
+			b = append(b, f.Key...)
+			b = append(b, '=')
+		}
+		b = append_logfmt_value(b, f.Value)
+	}
+	b = append(b, '\n')
+	return b
+}
+
+func append_padding(b []byte, n int) []byte {
+	for ; n > 0; n-- {
+		b = append(b, ' ')
+	}
+	return b
+}
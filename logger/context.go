@@ -0,0 +1,97 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type context_key int
+
+const (
+	logger_context_key context_key = iota
+	request_id_context_key
+)
+
+type context_field struct {
+	key    interface{}
+	name   string
+	format func(v interface{}) string
+}
+
+var (
+	context_fields_mutex sync.RWMutex
+	context_fields       = []context_field{{request_id_context_key, "request_id", nil}}
+)
+
+// RegisterContextKey makes FromContext add ctx.Value(key), when set, as the
+// field name of every entry.
+func RegisterContextKey(key interface{}, name string) {
+	RegisterContextKeyFunc(key, name, nil)
+}
+
+// RegisterContextKeyFunc is RegisterContextKey with the field value being
+// format(ctx.Value(key)), fmt.Sprint if format is nil.
+func RegisterContextKeyFunc(key interface{}, name string, format func(v interface{}) string) {
+	context_fields_mutex.Lock()
+	defer context_fields_mutex.Unlock()
+
+	for i := range context_fields {
+		if context_fields[i].key == key {
+			context_fields[i].name = name
+			context_fields[i].format = format
+			return
+		}
+	}
+	context_fields = append(context_fields, context_field{key, name, format})
+}
+
+// NewContext returns a copy of ctx carrying l, for FromContext. Named("")
+// turns a Logger or SimpleLogger itself into one, sharing its level; give a
+// name for a child whose level can be set apart.
+func NewContext(ctx context.Context, l *NamedLogger) context.Context {
+	return context.WithValue(ctx, logger_context_key, l)
+}
+
+// FromContext returns the logger carried by ctx, or a child of the global
+// logger, with the registered context keys set in ctx added as fields.
+func FromContext(ctx context.Context) *NamedLogger {
+	l, ok := ctx.Value(logger_context_key).(*NamedLogger)
+	if !ok {
+		l = simpleLg.Named("")
+	}
+
+	fields := append_context_fields(nil, ctx)
+	if len(fields) == 0 {
+		return l
+	}
+	return &NamedLogger{name: l.name, c: l.c, fields: append(l.fields[:len(l.fields):len(l.fields)], fields...)}
+}
+
+// append_context_fields appends the registered context keys set in ctx.
+func append_context_fields(fields []Field, ctx context.Context) []Field {
+	context_fields_mutex.RLock()
+	defer context_fields_mutex.RUnlock()
+
+	for _, cf := range context_fields {
+		if v := ctx.Value(cf.key); v != nil {
+			if cf.format != nil {
+				fields = append(fields, Field{cf.name, cf.format(v)})
+			} else {
+				fields = append(fields, Field{cf.name, fmt.Sprint(v)})
+			}
+		}
+	}
+	return fields
+}
+
+// NewRequestIDContext returns a copy of ctx carrying id, logged as the
+// request_id field by loggers from FromContext.
+func NewRequestIDContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, request_id_context_key, id)
+}
+
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(request_id_context_key).(string)
+	return id, ok
+}
@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+type test_context_key struct{}
+
+func TestFromContext(t *testing.T) {
+	RegisterContextKey(test_context_key{}, "user")
+
+	w := &buffer_closer{}
+	l := NewSimpleLogger(w)
+	l.EnableCallerInfo()
+
+	ctx := NewContext(context.Background(), l.Named("api"))
+	ctx = NewRequestIDContext(ctx, "req-1")
+	ctx = context.WithValue(ctx, test_context_key{}, "alice smith")
+
+	FromContext(ctx).Info("hello")
+	FromContext(ctx).Named("db").With("table", 42).Warn("slow")
+
+	lines := strings.Split(strings.TrimSpace(w.buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), w.buf.Bytes())
+	}
+	if !strings.Contains(lines[0], "{api} [") || !strings.HasSuffix(lines[0], `hello request_id=req-1 user="alice smith"`) {
+		t.Errorf("line 1: %s", lines[0])
+	}
+	if !strings.Contains(lines[0], "context_test.go:TestFromContext") {
+		t.Errorf("line 1 caller: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "{api.db} [") || !strings.HasSuffix(lines[1], `slow request_id=req-1 user="alice smith" table=42`) {
+		t.Errorf("line 2: %s", lines[1])
+	}
+}
+
+func TestFromContextGlobal(t *testing.T) {
+	w := &buffer_closer{}
+	SetWriter(w)
+	defer SetWriter(os.Stdout)
+
+	ctx := NewRequestIDContext(context.Background(), "req-2")
+	FromContext(ctx).Info("global")
+	FromContext(context.Background()).Info("plain")
+
+	out := w.buf.String()
+	if !strings.Contains(out, "global request_id=req-2\n") || !strings.Contains(out, "plain\n") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
+
+type test_format_key struct{}
+
+func TestRegisterContextKeyFunc(t *testing.T) {
+	RegisterContextKeyFunc(test_format_key{}, "n", func(v interface{}) string {
+		return strings.Repeat("x", v.(int))
+	})
+
+	w := &buffer_closer{}
+	l := NewSimpleLogger(w)
+	ctx := NewContext(context.WithValue(context.Background(), test_format_key{}, 3), l.Named(""))
+	FromContext(ctx).Info("m")
+
+	if out := w.buf.String(); !strings.HasSuffix(out, "m n=xxx\n") {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestFieldsEncoders(t *testing.T) {
+	e := &Entry{
+		TimeFormat: default_time_format,
+		Level:      LevelInfo,
+		Message:    "m",
+		Fields:     []Field{{"request_id", "r 1"}},
+	}
+
+	if s := string(JSONEncoder{}.Encode(e)); !strings.HasSuffix(s, `"msg":"m","request_id":"r 1"}`+"\n") {
+		t.Errorf("json: %s", s)
+	}
+	if s := string(LogfmtEncoder{}.Encode(e)); !strings.HasSuffix(s, `msg=m request_id="r 1"`+"\n") {
+		t.Errorf("logfmt: %s", s)
+	}
+	if name := journald_field_name("request-id"); name != "REQUEST_ID" {
+		t.Errorf("journald field name: %s", name)
+	}
+}
@@ -0,0 +1,229 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Interface is implemented by Logger and SimpleLogger. LogFormator has the
+// same methods, except for its level methods returning the formatted line.
+type Interface interface {
+	Enable()
+	Disable()
+	SetLevel(lv Level)
+	SetTimeFormat(format string)
+	SetEncoder(enc Encoder)
+	EnableCallerInfo()
+	DisableCallerInfo()
+	SetCallerFormat(f CallerFormat)
+	SetSampling(cfg *SampleConfig)
+	Named(name string) *NamedLogger
+
+	Debug4(format string, a ...interface{})
+	Debug3(format string, a ...interface{})
+	Debug2(format string, a ...interface{})
+	Debug1(format string, a ...interface{})
+	Debug(format string, a ...interface{})
+	Info(format string, a ...interface{})
+	Warn(format string, a ...interface{})
+	Error(format string, a ...interface{})
+	Critical(format string, a ...interface{})
+	Output(skip int, level Level, format string, a ...interface{})
+}
+
+var (
+	_ Interface = (*Logger)(nil)
+	_ Interface = (*SimpleLogger)(nil)
+)
+
+// core holds the settings and level methods shared by Logger, SimpleLogger
+// and LogFormator, which differ only in what output does with an entry.
+type core struct {
+	mutex sync.Mutex
+
+	disable            bool
+	time_format        string
+	level              uint32 // Level, changed by other goroutines through Levels
+	enable_caller_info bool
+	caller_path_number int
+	caller_format      CallerFormat
+	encoder            Encoder
+	levels             *LevelTree
+	sampler            atomic.Value // *sampler, replaced by SetSampling
+	hooks              atomic.Value
+
+	output func(e *Entry)
+
+	System string
+}
+
+func (c *core) init(output func(e *Entry)) {
+	c.time_format = default_time_format
+	c.set_root_level(LevelDebug)
+	c.caller_path_number = 3
+	c.encoder = default_encoder
+	c.levels = new_level_tree(c.root_level, c.set_root_level)
+	c.output = output
+}
+
+func (c *core) Enable() {
+	c.disable = false
+}
+
+func (c *core) Disable() {
+	c.disable = true
+}
+
+func (c *core) SetLevel(lv Level) {
+	c.set_root_level(lv)
+}
+
+func (c *core) root_level() Level {
+	return Level(atomic.LoadUint32(&c.level))
+}
+
+func (c *core) set_root_level(lv Level) {
+	atomic.StoreUint32(&c.level, uint32(lv))
+}
+
+// Levels returns the level overrides of the child loggers created by Named.
+func (c *core) Levels() *LevelTree {
+	return c.levels
+}
+
+// Named returns a child logger writing through c, its level can be set
+// apart with Levels().SetLevel(name, lv).
+func (c *core) Named(name string) *NamedLogger {
+	return &NamedLogger{name: name, c: c}
+}
+
+func (c *core) SetTimeFormat(format string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.time_format = format
+}
+
+func (c *core) SetEncoder(enc Encoder) {
+	if enc == nil {
+		panic("SetEncoder enc is null")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.encoder = enc
+}
+
+func (c *core) EnableCallerInfo() {
+	c.enable_caller_info = true
+}
+
+func (c *core) DisableCallerInfo() {
+	c.enable_caller_info = false
+}
+
+func (c *core) enabled(name string, level Level) bool {
+	if c.disable {
+		return false
+	}
+	if name == "" {
+		return c.root_level() <= level
+	}
+	return c.levels.Level(name) <= level
+}
+
+// log writes an entry of the child logger name, or of c itself if name is
+// empty. depth is the stack depth of the code which logged it, as passed
+// by the level methods.
+func (c *core) log(depth int, name string, fields []Field, level Level, format string, a ...interface{}) {
+	if !c.enabled(name, level) {
+		return
+	}
+
+	s := c.sampling()
+	var pc uintptr
+	if c.enable_caller_info || s != nil {
+		pc = get_caller_pc(depth)
+	}
+	c.log_pc(s, pc, name, fields, level, format, a...)
+}
+
+// log_pc is log for an entry logged at the program counter pc, 0 if unknown,
+// s being the sampler loaded by the caller.
+func (c *core) log_pc(s *sampler, pc uintptr, name string, fields []Field, level Level, format string, a ...interface{}) {
+	if e := c.entry(s, pc, name, fields, level, format, a...); e != nil {
+		c.output(e)
+	}
+}
+
+// entry returns the entry to output, after sampling and hooks, or nil if
+// the sampler s drops it.
+func (c *core) entry(s *sampler, pc uintptr, name string, fields []Field, level Level, format string, a ...interface{}) *Entry {
+	if s != nil && !c.sample(s, pc, name, level, format) {
+		return nil
+	}
+
+	e := c.new_entry(pc, name, level, format, a...)
+	e.Fields = fields
+	c.fire_hooks(e)
+	return e
+}
+
+func (c *core) new_entry(pc uintptr, name string, level Level, format string, a ...interface{}) *Entry {
+	e := &Entry{
+		Time:       time.Now(),
+		TimeFormat: c.time_format,
+		Level:      level,
+		System:     c.System,
+		Name:       name,
+		Message:    fmt.Sprintf(format, a...),
+	}
+
+	if c.enable_caller_info {
+		e.Caller = get_caller_info(pc).format(c.caller_format)
+	}
+	return e
+}
+
+// Output writes an entry as if logged skip frames above its caller, for
+// wrappers to report their own caller: skip 0 is the code calling Output.
+func (c *core) Output(skip int, level Level, format string, a ...interface{}) {
+	c.log(c.caller_path_number+skip, "", nil, level, format, a...)
+}
+
+func (c *core) Debug4(format string, a ...interface{}) {
+	c.log(c.caller_path_number, "", nil, LevelDebug4, format, a...)
+}
+
+func (c *core) Debug3(format string, a ...interface{}) {
+	c.log(c.caller_path_number, "", nil, LevelDebug3, format, a...)
+}
+
+func (c *core) Debug2(format string, a ...interface{}) {
+	c.log(c.caller_path_number, "", nil, LevelDebug2, format, a...)
+}
+
+func (c *core) Debug1(format string, a ...interface{}) {
+	c.log(c.caller_path_number, "", nil, LevelDebug1, format, a...)
+}
+
+func (c *core) Debug(format string, a ...interface{}) {
+	c.log(c.caller_path_number, "", nil, LevelDebug, format, a...)
+}
+
+func (c *core) Info(format string, a ...interface{}) {
+	c.log(c.caller_path_number, "", nil, LevelInfo, format, a...)
+}
+
+func (c *core) Warn(format string, a ...interface{}) {
+	c.log(c.caller_path_number, "", nil, LevelWarn, format, a...)
+}
+
+func (c *core) Error(format string, a ...interface{}) {
+	c.log(c.caller_path_number, "", nil, LevelError, format, a...)
+}
+
+func (c *core) Critical(format string, a ...interface{}) {
+	c.log(c.caller_path_number, "", nil, LevelCrit, format, a...)
+}
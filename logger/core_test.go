@@ -0,0 +1,198 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// implementations returns every Interface implementation, each with a
+// function returning what it has written so far.
+func implementations() map[string]func() (Interface, func() string) {
+	return map[string]func() (Interface, func() string){
+		"Logger": func() (Interface, func() string) {
+			w := &buffer_closer{}
+			l := NewLogger(w)
+			return l, func() string {
+				l.Flush()
+				return w.buf.String()
+			}
+		},
+		"SimpleLogger": func() (Interface, func() string) {
+			w := &buffer_closer{}
+			return NewSimpleLogger(w), w.buf.String
+		},
+		"LogFormator": func() (Interface, func() string) {
+			var buf bytes.Buffer
+			return formator_logger{NewLogFormator(func(b []byte) { buf.Write(b) })}, buf.String
+		},
+	}
+}
+
+// formator_logger is a LogFormator as an Interface, which its level methods
+// returning the line do not implement.
+type formator_logger struct {
+	*LogFormator
+}
+
+func (l formator_logger) Debug4(format string, a ...interface{}) {
+	l.Output(1, LevelDebug4, format, a...)
+}
+
+func (l formator_logger) Debug3(format string, a ...interface{}) {
+	l.Output(1, LevelDebug3, format, a...)
+}
+
+func (l formator_logger) Debug2(format string, a ...interface{}) {
+	l.Output(1, LevelDebug2, format, a...)
+}
+
+func (l formator_logger) Debug1(format string, a ...interface{}) {
+	l.Output(1, LevelDebug1, format, a...)
+}
+
+func (l formator_logger) Debug(format string, a ...interface{}) {
+	l.Output(1, LevelDebug, format, a...)
+}
+
+func (l formator_logger) Info(format string, a ...interface{}) {
+	l.Output(1, LevelInfo, format, a...)
+}
+
+func (l formator_logger) Warn(format string, a ...interface{}) {
+	l.Output(1, LevelWarn, format, a...)
+}
+
+func (l formator_logger) Error(format string, a ...interface{}) {
+	l.Output(1, LevelError, format, a...)
+}
+
+func (l formator_logger) Critical(format string, a ...interface{}) {
+	l.Output(1, LevelCrit, format, a...)
+}
+
+func log_all_levels(l Interface) {
+	l.Debug4("debug4 %d", 4)
+	l.Debug3("debug3 %d", 3)
+	l.Debug2("debug2 %d", 2)
+	l.Debug1("debug1 %d", 1)
+	l.Debug("debug")
+	l.Info("info %s", "x")
+	l.Warn("warn")
+	l.Error("error")
+	l.Critical("critical")
+}
+
+func TestImplementationsSameOutput(t *testing.T) {
+	want := "" +
+		" [DEBUG2] || debug2 2\n" +
+		" [DEBUG1] || debug1 1\n" +
+		" [DEBUG] || debug\n" +
+		" [INFO] || info x\n" +
+		" [WARN] || warn\n" +
+		" [ERROR] || error\n" +
+		" [CRITICAL] || critical\n"
+
+	for name, impl := range implementations() {
+		l, output := impl()
+		l.SetTimeFormat("")
+		l.SetLevel(LevelDebug2)
+		log_all_levels(l)
+
+		if got := output(); got != want {
+			t.Errorf("%s:\n got %q\nwant %q", name, got, want)
+		}
+	}
+}
+
+func TestImplementationsDisable(t *testing.T) {
+	for name, impl := range implementations() {
+		l, output := impl()
+		l.Disable()
+		log_all_levels(l)
+		l.Enable()
+		l.SetLevel(LevelCrit)
+		log_all_levels(l)
+
+		if got := output(); strings.Count(got, "\n") != 1 || !strings.Contains(got, "[CRITICAL] || critical") {
+			t.Errorf("%s: got %q", name, got)
+		}
+	}
+}
+
+func TestImplementationsCallerInfo(t *testing.T) {
+	for name, impl := range implementations() {
+		l, output := impl()
+		l.EnableCallerInfo()
+		l.Info("direct")
+		l.Named("child").Info("named")
+
+		got := output()
+		if n := strings.Count(got, "/logger:core_test.go:TestImplementationsCallerInfo(..):"); n != 2 {
+			t.Errorf("%s: caller info wrong:\n%s", name, got)
+		}
+	}
+}
+
+func TestImplementationsEncoder(t *testing.T) {
+	for name, impl := range implementations() {
+		l, output := impl()
+		l.SetEncoder(JSONEncoder{})
+		l.Info("json")
+
+		if got := output(); !strings.HasPrefix(got, `{"time":`) {
+			t.Errorf("%s: got %q", name, got)
+		}
+	}
+}
+
+func TestLogFormatorFormat(t *testing.T) {
+	f := NewDefaultLogFormator()
+	f.SetTimeFormat("")
+	f.SetLevel(LevelWarn)
+
+	if b := f.Format(LevelInfo, "info"); b != nil {
+		t.Errorf("filtered entry formatted: %q", b)
+	}
+	if b := string(f.Format(LevelCrit, "crit %d", 1)); b != " [CRITICAL] || crit 1\n" {
+		t.Errorf("got %q", b)
+	}
+}
+
+func TestLogFormatorLevelMethods(t *testing.T) {
+	var handled []string
+	f := NewLogFormator(func(b []byte) { handled = append(handled, string(b)) })
+	f.SetTimeFormat("")
+	f.SetLevel(LevelWarn)
+
+	if b := f.Info("info"); b != nil {
+		t.Errorf("filtered entry formatted: %q", b)
+	}
+	if b := string(f.Critical("crit %d", 1)); b != " [CRITICAL] || crit 1\n" {
+		t.Errorf("got %q", b)
+	}
+
+	f.EnableCallerInfo()
+	b := string(f.Warn("warn"))
+	if !strings.Contains(b, "/logger:core_test.go:TestLogFormatorLevelMethods(..):") {
+		t.Errorf("caller info wrong: %s", b)
+	}
+
+	if len(handled) != 2 || handled[1] != b {
+		t.Errorf("handled %q", handled)
+	}
+}
+
+func TestGlobalCallerInfo(t *testing.T) {
+	w := &buffer_closer{}
+	SetWriter(w)
+	defer SetWriter(os.Stdout)
+
+	EnableCallerInfo()
+	Info("global")
+
+	if !strings.Contains(w.buf.String(), "/logger:core_test.go:TestGlobalCallerInfo(..):") {
+		t.Errorf("caller info wrong: %s", w.buf.Bytes())
+	}
+}
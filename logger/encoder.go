@@ -0,0 +1,276 @@
+package logger
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Field is a key=value pair attached to an entry, see NamedLogger.With and
+// FromContext.
+type Field struct {
+	Key   string
+	Value string
+}
+
+// Entry is one log record, handed to an Encoder to be turned into bytes.
+type Entry struct {
+	Time       time.Time
+	TimeFormat string
+	Level      Level
+	System     string
+	Name       string
+	Caller     string
+	Message    string
+	Fields     []Field
+	// Stack is the stack of the logging goroutine, captured for hooks
+	// asking for it and not written by encoders.
+	Stack []byte
+}
+
+// Encoder serializes an Entry into one line, including the trailing newline.
+type Encoder interface {
+	Encode(e *Entry) []byte
+}
+
+// AppendEncoder is an Encoder able to append the line to a buffer of the
+// caller, which saves allocating one per entry. All encoders of this
+// package implement it.
+type AppendEncoder interface {
+	Encoder
+	AppendEntry(b []byte, e *Entry) []byte
+}
+
+// TextEncoder writes the classic layout:
+//
+//	2006/01/02 15:04:05.000 [LEVEL] |SYSTEM| {name} [pkg:file:func(..):line] msg key=value
+//
+// An empty SYSTEM still gives "||", as the loggers always wrote.
+type TextEncoder struct{}
+
+// JSONEncoder writes one JSON object per line.
+type JSONEncoder struct{}
+
+// LogfmtEncoder writes space separated key=value pairs.
+type LogfmtEncoder struct{}
+
+var (
+	default_encoder Encoder = TextEncoder{}
+)
+
+func StringToEncoder(s string) Encoder {
+	switch strings.ToLower(s) {
+	case "json":
+		return JSONEncoder{}
+	case "logfmt":
+		return LogfmtEncoder{}
+	case "console":
+		return console_auto{}
+	default:
+		return TextEncoder{}
+	}
+}
+
+func (enc TextEncoder) Encode(e *Entry) []byte {
+	return enc.AppendEntry(make([]byte, 0, 64+len(e.Caller)+len(e.Message)), e)
+}
+
+func (TextEncoder) AppendEntry(b []byte, e *Entry) []byte {
+	b = e.Time.AppendFormat(b, e.TimeFormat)
+	b = append(b, " ["...)
+	b = append(b, e.Level.String()...)
+	b = append(b, "] "...)
+	b = append(b, '|')
+	b = append(b, e.System...)
+	b = append(b, "| "...)
+	if e.Name != "" {
+		b = append(b, '{')
+		b = append(b, e.Name...)
+		b = append(b, "} "...)
+	}
+	if e.Caller != "" {
+		b = append(b, '[')
+		b = append(b, e.Caller...)
+		b = append(b, "] "...)
+	}
+	b = append(b, e.Message...)
+	b = append_logfmt_fields(b, e.Fields)
+	b = append(b, '\n')
+	return b
+}
+
+func (enc JSONEncoder) Encode(e *Entry) []byte {
+	return enc.AppendEntry(make([]byte, 0, 96+len(e.Caller)+len(e.Message)), e)
+}
+
+func (JSONEncoder) AppendEntry(b []byte, e *Entry) []byte {
+	b = append(b, `{"time":`...)
+	b = append_json_time(b, e.Time, e.TimeFormat)
+	b = append(b, `,"level":"`...)
+	b = append(b, e.Level.String()...)
+	b = append(b, '"')
+	if e.System != "" {
+		b = append(b, `,"system":`...)
+		b = append_json_string(b, e.System)
+	}
+	if e.Name != "" {
+		b = append(b, `,"logger":`...)
+		b = append_json_string(b, e.Name)
+	}
+	if e.Caller != "" {
+		b = append(b, `,"caller":`...)
+		b = append_json_string(b, e.Caller)
+	}
+	b = append(b, `,"msg":`...)
+	b = append_json_string(b, e.Message)
+	for _, f := range e.Fields {
+		b = append(b, ',')
+		b = append_json_string(b, f.Key)
+		b = append(b, ':')
+		b = append_json_string(b, f.Value)
+	}
+	b = append(b, "}\n"...)
+	return b
+}
+
+func (enc LogfmtEncoder) Encode(e *Entry) []byte {
+	return enc.AppendEntry(make([]byte, 0, 96+len(e.Caller)+len(e.Message)), e)
+}
+
+func (LogfmtEncoder) AppendEntry(b []byte, e *Entry) []byte {
+	b = append(b, "time="...)
+	b = append_logfmt_time(b, e.Time, e.TimeFormat)
+	b = append(b, " level="...)
+	b = append(b, e.Level.lower()...)
+	if e.System != "" {
+		b = append(b, " system="...)
+		b = append_logfmt_value(b, e.System)
+	}
+	if e.Name != "" {
+		b = append(b, " logger="...)
+		b = append_logfmt_value(b, e.Name)
+	}
+	if e.Caller != "" {
+		b = append(b, " caller="...)
+		b = append_logfmt_value(b, e.Caller)
+	}
+	b = append(b, " msg="...)
+	b = append_logfmt_value(b, e.Message)
+	b = append_logfmt_fields(b, e.Fields)
+	b = append(b, '\n')
+	return b
+}
+
+// append_entry appends the line of e encoded by enc to b.
+func append_entry(enc Encoder, b []byte, e *Entry) []byte {
+	if ae, ok := enc.(AppendEncoder); ok {
+		return ae.AppendEntry(b, e)
+	}
+	return append(b, enc.Encode(e)...)
+}
+
+const hex_digits = "0123456789abcdef"
+
+// append_json_time appends t as a JSON string, formatting it in place
+// unless the layout yields characters which need escaping.
+func append_json_time(b []byte, t time.Time, layout string) []byte {
+	start := len(b)
+	b = append(b, '"')
+	b = t.AppendFormat(b, layout)
+	for _, c := range b[start+1:] {
+		if c < 0x20 || c == '"' || c == '\\' || c >= utf8.RuneSelf {
+			return append_json_string(b[:start], string(b[start+1:]))
+		}
+	}
+	return append(b, '"')
+}
+
+// append_logfmt_time is append_logfmt_value for t, formatted in place.
+func append_logfmt_time(b []byte, t time.Time, layout string) []byte {
+	start := len(b)
+	b = append(b, '"')
+	b = t.AppendFormat(b, layout)
+
+	quote := len(b) == start+1
+	for _, c := range b[start+1:] {
+		if c < ' ' || c == '"' || c == '\\' || c >= utf8.RuneSelf {
+			return append_logfmt_value(b[:start], string(b[start+1:]))
+		}
+		if c == ' ' || c == '=' {
+			quote = true
+		}
+	}
+
+	if quote {
+		return append(b, '"')
+	}
+	copy(b[start:], b[start+1:])
+	return b[:len(b)-1]
+}
+
+func append_json_string(b []byte, s string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hex_digits[c>>4], hex_digits[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
+
+func append_logfmt_fields(b []byte, fields []Field) []byte {
+	for _, f := range fields {
+		b = append(b, ' ')
+		b = append(b, f.Key...)
+		b = append(b, '=')
+		b = append_logfmt_value(b, f.Value)
+	}
+	return b
+}
+
+func append_logfmt_value(b []byte, s string) []byte {
+	if s == "" {
+		return append(b, `""`...)
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c == '=' || c == '"' || c >= utf8.RuneSelf {
+			return strconv.AppendQuote(b, s)
+		}
+	}
+	return append(b, s...)
+}
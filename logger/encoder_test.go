@@ -0,0 +1,142 @@
+package logger
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncoders(t *testing.T) {
+	e := &Entry{
+		Time:       time.Date(2017, 7, 28, 17, 14, 36, 928000000, time.UTC),
+		TimeFormat: default_time_format,
+		Level:      LevelError,
+		System:     "DEMO",
+		Caller:     "main:demo.go:main(..):35",
+		Message:    `say "hi"`,
+	}
+
+	cases := []struct {
+		Enc  Encoder
+		Want string
+	}{
+		{TextEncoder{}, `2017/07/28 17:14:36.928 [ERROR] |DEMO| [main:demo.go:main(..):35] say "hi"` + "\n"},
+		{JSONEncoder{}, `{"time":"2017/07/28 17:14:36.928","level":"ERROR","system":"DEMO","caller":"main:demo.go:main(..):35","msg":"say \"hi\""}` + "\n"},
+		{ConsoleEncoder{CallerWidth: 26}, `2017/07/28 17:14:36.928 ERROR    |DEMO| main:demo.go:main(..):35   say "hi"` + "\n"},
+		{ConsoleEncoder{Color: true, CallerWidth: 10}, "2017/07/28 17:14:36.928 \x1b[31mERROR\x1b[0m    |DEMO| \x1b[2m..n(..):35\x1b[0m say \"hi\"\n"},
+		{ConsoleEncoder{CallerWidth: 3}, `2017/07/28 17:14:36.928 ERROR    |DEMO| ..5 say "hi"` + "\n"},
+		{ConsoleEncoder{CallerWidth: 2}, `2017/07/28 17:14:36.928 ERROR    |DEMO| 35 say "hi"` + "\n"},
+		{ConsoleEncoder{CallerWidth: 1}, `2017/07/28 17:14:36.928 ERROR    |DEMO| 5 say "hi"` + "\n"},
+		{LogfmtEncoder{}, `time="2017/07/28 17:14:36.928" level=error system=DEMO caller=main:demo.go:main(..):35 msg="say \"hi\""` + "\n"},
+	}
+
+	for _, c := range cases {
+		if got := string(c.Enc.Encode(e)); got != c.Want {
+			t.Errorf("%T:\n got %q\nwant %q", c.Enc, got, c.Want)
+		}
+	}
+}
+
+// TestTextEncoderBaseline compares TextEncoder to the lines the loggers
+// wrote before encoders, "||" standing for an empty System.
+func TestTextEncoderBaseline(t *testing.T) {
+	for _, c := range []struct {
+		System, Caller, Want string
+	}{
+		{"", "", "07/28 17:14:36 [INFO] || info: 2\n"},
+		{"", "main:demo.go:main(..):35", "07/28 17:14:36 [INFO] || [main:demo.go:main(..):35] info: 2\n"},
+		{"DEMO", "", "07/28 17:14:36 [INFO] |DEMO| info: 2\n"},
+		{"DEMO", "main:demo.go:main(..):35", "07/28 17:14:36 [INFO] |DEMO| [main:demo.go:main(..):35] info: 2\n"},
+	} {
+		e := &Entry{
+			Time:       time.Date(2017, 7, 28, 17, 14, 36, 0, time.UTC),
+			TimeFormat: "01/02 15:04:05",
+			Level:      LevelInfo,
+			System:     c.System,
+			Caller:     c.Caller,
+			Message:    "info: 2",
+		}
+		if got := string(TextEncoder{}.Encode(e)); got != c.Want {
+			t.Errorf("got %q, want %q", got, c.Want)
+		}
+	}
+}
+
+func TestJSONEscape(t *testing.T) {
+	got := string(append_json_string(nil, "a\tb\x01\\\xff"))
+	want := `"a\tb\u0001\\` + "\ufffd" + `"`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestNewConsoleEncoder(t *testing.T) {
+	f, err := ioutil.TempFile("", "console")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, ok := NewConsoleEncoder(f).(TextEncoder); !ok {
+		t.Errorf("got %T for a regular file, want TextEncoder", NewConsoleEncoder(f))
+	}
+
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer null.Close()
+	if _, ok := NewConsoleEncoder(null).(TextEncoder); !ok {
+		t.Errorf("got %T for %s, want TextEncoder", NewConsoleEncoder(null), os.DevNull)
+	}
+}
+
+// TestConsoleEncoderDestination checks that "console" looks at the file
+// written to, not at os.Stdout.
+func TestConsoleEncoderDestination(t *testing.T) {
+	for name, impl := range map[string]func(f *os.File) Interface{
+		"Logger":       func(f *os.File) Interface { return NewLogger(f) },
+		"SimpleLogger": func(f *os.File) Interface { return NewSimpleLogger(f) },
+	} {
+		f, err := ioutil.TempFile("", "console")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+
+		l := impl(f)
+		l.SetEncoder(StringToEncoder("console"))
+		l.Info("hello")
+		l.(interface{ Close() }).Close()
+
+		b, _ := ioutil.ReadFile(f.Name())
+		if !strings.HasSuffix(string(b), " [INFO] || hello\n") {
+			t.Errorf("%s: got %q, want a text line", name, b)
+		}
+	}
+}
+
+func TestAppendTime(t *testing.T) {
+	tm := time.Date(2017, 7, 28, 17, 14, 36, 0, time.UTC)
+
+	cases := []struct {
+		Layout, JSON, Logfmt string
+	}{
+		{"20060102", `"20170728"`, `20170728`},
+		{"2006/01/02 15:04", `"2017/07/28 17:14"`, `"2017/07/28 17:14"`},
+		{`"Jan"`, `"\"Jul\""`, `"\"Jul\""`},
+		{"", `""`, `""`},
+	}
+
+	for _, c := range cases {
+		if got := string(append_json_time([]byte("x"), tm, c.Layout)); got != "x"+c.JSON {
+			t.Errorf("json %q: got %s, want %s", c.Layout, got, c.JSON)
+		}
+		if got := string(append_logfmt_time([]byte("x"), tm, c.Layout)); got != "x"+c.Logfmt {
+			t.Errorf("logfmt %q: got %s, want %s", c.Layout, got, c.Logfmt)
+		}
+	}
+}
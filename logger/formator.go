@@ -1,168 +1,100 @@
 package logger
 
-import (
-	"fmt"
-	"sync"
-	"time"
-)
-
 //Mon Jan 2 15:04:05 -0700 MST 2006
 
+// LogFormator formats entries without writing them: the level methods return
+// the formatted line and hand it to the handler given to NewLogFormator,
+// Format only returns it.
 type LogFormator struct {
-	mutex              sync.Mutex
-	disable            bool
-	time_format        string
-	level              Level
-	enable_caller_info bool
-	caller_path_number int
-
-	System string
+	core
+
+	handler func(b []byte)
 }
 
 func NewDefaultLogFormator() *LogFormator {
+	return NewLogFormator(nil)
+}
+
+func NewLogFormator(handler func(b []byte)) *LogFormator {
 	l := &LogFormator{
-		time_format:        default_time_format,
-		level:              LevelDebug,
-		enable_caller_info: false,
-		caller_path_number: 3,
+		handler: handler,
 	}
+	l.core.init(l.output)
 	return l
 }
 
-func (l *LogFormator) Enable() {
-	l.disable = false
-}
-
-func (l *LogFormator) Disable() {
-	l.disable = true
-}
-
-func (l *LogFormator) SetLevel(lv Level) {
-	l.level = lv
-}
-
-func (l *LogFormator) SetTimeFormat(format string) {
-	l.mutex.Lock()
-	defer l.mutex.Unlock()
-	l.time_format = format
+func (l *LogFormator) output(e *Entry) {
+	if l.handler != nil {
+		l.handler(l.encoder.Encode(e))
+	}
 }
 
-func (l *LogFormator) EnableCallerInfo() {
-	l.enable_caller_info = true
+// Format returns the formatted line, or nil if level is filtered out.
+func (l *LogFormator) Format(level Level, format string, a ...interface{}) []byte {
+	if !l.enabled("", level) {
+		return nil
+	}
+	// one frame less than the level methods, which go through format
+	pc := get_caller_pc(l.caller_path_number - 1)
+	return l.encoder.Encode(l.new_entry(pc, "", level, format, a...))
 }
 
-func (l *LogFormator) DisableCallerInfo() {
-	l.enable_caller_info = false
-}
+// format is log for the level methods of l, also returning the line.
+func (l *LogFormator) format(level Level, format string, a ...interface{}) []byte {
+	if !l.enabled("", level) {
+		return nil
+	}
 
-func (l *LogFormator) format(level_str string, format string, a ...interface{}) []byte {
-	var s string
-
-	if !l.enable_caller_info {
-		if l.System != "" {
-			s = fmt.Sprintf(time.Now().Format(l.time_format)+" ["+level_str+"] |"+l.System+"| "+format+"\n", a...)
-		} else {
-			s = fmt.Sprintf(time.Now().Format(l.time_format)+" ["+level_str+"] |"+l.System+"| "+format+"\n", a...)
-		}
-	} else {
-		if l.System != "" {
-			s = fmt.Sprintf(time.Now().Format(l.time_format)+" ["+level_str+"] |"+l.System+"| ["+get_caller_info(l.caller_path_number).String()+"] "+format+"\n", a...)
-		} else {
-			s = fmt.Sprintf(time.Now().Format(l.time_format)+" ["+level_str+"] |"+l.System+"| ["+get_caller_info(l.caller_path_number).String()+"] "+format+"\n", a...)
-		}
+	s := l.sampling()
+	var pc uintptr
+	if l.enable_caller_info || s != nil {
+		pc = get_caller_pc(l.caller_path_number)
+	}
 
+	e := l.entry(s, pc, "", nil, level, format, a...)
+	if e == nil {
+		return nil
 	}
 
-	return StringToReadonlySlice(&s)
+	b := l.encoder.Encode(e)
+	if l.handler != nil {
+		l.handler(b)
+	}
+	return b
 }
 
 func (l *LogFormator) Debug4(format string, a ...interface{}) []byte {
-	if l.disable {
-		return nil
-	}
-	if l.level <= LevelDebug4 {
-		return l.format("DEBUG4", format, a...)
-	}
-	return nil
+	return l.format(LevelDebug4, format, a...)
 }
 
 func (l *LogFormator) Debug3(format string, a ...interface{}) []byte {
-	if l.disable {
-		return nil
-	}
-	if l.level <= LevelDebug3 {
-		return l.format("DEBUG3", format, a...)
-	}
-	return nil
+	return l.format(LevelDebug3, format, a...)
 }
 
 func (l *LogFormator) Debug2(format string, a ...interface{}) []byte {
-	if l.disable {
-		return nil
-	}
-	if l.level <= LevelDebug2 {
-		return l.format("DEBUG2", format, a...)
-	}
-	return nil
+	return l.format(LevelDebug2, format, a...)
 }
 
 func (l *LogFormator) Debug1(format string, a ...interface{}) []byte {
-	if l.disable {
-		return nil
-	}
-	if l.level <= LevelDebug1 {
-		return l.format("DEBUG1", format, a...)
-	}
-	return nil
+	return l.format(LevelDebug1, format, a...)
 }
 
 func (l *LogFormator) Debug(format string, a ...interface{}) []byte {
-	if l.disable {
-		return nil
-	}
-	if l.level <= LevelDebug {
-		return l.format("DEBUG", format, a...)
-	}
-	return nil
+	return l.format(LevelDebug, format, a...)
 }
 
 func (l *LogFormator) Info(format string, a ...interface{}) []byte {
-	if l.disable {
-		return nil
-	}
-	if l.level <= LevelInfo {
-		return l.format("INFO", format, a...)
-	}
-
-	return nil
+	return l.format(LevelInfo, format, a...)
 }
 
 func (l *LogFormator) Warn(format string, a ...interface{}) []byte {
-	if l.disable {
-		return nil
-	}
-	if l.level <= LevelWarn {
-		return l.format("WARN", format, a...)
-	}
-	return nil
+	return l.format(LevelWarn, format, a...)
 }
 
 func (l *LogFormator) Error(format string, a ...interface{}) []byte {
-	if l.disable {
-		return nil
-	}
-	if l.level <= LevelError {
-		return l.format("ERROR", format, a...)
-	}
-	return nil
+	return l.format(LevelError, format, a...)
 }
 
 func (l *LogFormator) Critical(format string, a ...interface{}) []byte {
-	if l.disable {
-		return nil
-	}
-	if l.level <= LevelCrit {
-		l.format("CRITICAL", format, a...)
-	}
-	return nil
+	return l.format(LevelCrit, format, a...)
 }
@@ -0,0 +1,138 @@
+package logger
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+const default_hook_queue_size = 1024
+
+type HookConfig struct {
+	// Level is the lowest level of the entries the hook is called with.
+	Level Level
+	// QueueSize is how many entries may wait for the hook, 1024 by default.
+	// Entries beyond are dropped and counted by Hook.Dropped.
+	QueueSize int
+	// Stack captures the stack of the logging goroutine into Entry.Stack.
+	Stack bool
+}
+
+// Hook runs a function on its own goroutine for the entries at or above
+// its level, such as alerting on ERROR and CRITICAL, without ever blocking
+// the code which logs them.
+type Hook struct {
+	cfg HookConfig
+	fn  func(e *Entry)
+
+	mutex   sync.RWMutex
+	closed  bool
+	queue   chan *Entry
+	dropped uint64
+	done    chan bool
+}
+
+// AddHook starts calling fn with the entries selected by cfg, until the
+// logger is closed. A nil cfg is the zero HookConfig.
+func (c *core) AddHook(fn func(e *Entry), cfg *HookConfig) *Hook {
+	h := &Hook{
+		fn:   fn,
+		done: make(chan bool),
+	}
+	if cfg != nil {
+		h.cfg = *cfg
+	}
+	if h.cfg.QueueSize <= 0 {
+		h.cfg.QueueSize = default_hook_queue_size
+	}
+	h.queue = make(chan *Entry, h.cfg.QueueSize)
+	go h.loop()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	hooks, _ := c.hooks.Load().([]*Hook)
+	c.hooks.Store(append(hooks[:len(hooks):len(hooks)], h))
+	return h
+}
+
+// Dropped returns how many entries were discarded because the queue of h
+// was full.
+func (h *Hook) Dropped() uint64 {
+	return atomic.LoadUint64(&h.dropped)
+}
+
+func (h *Hook) loop() {
+	defer close(h.done)
+
+	for e := range h.queue {
+		h.fn(e)
+	}
+}
+
+func (h *Hook) fire(e *Entry) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if h.closed {
+		return
+	}
+
+	select {
+	case h.queue <- e:
+	default:
+		atomic.AddUint64(&h.dropped, 1)
+	}
+}
+
+// close runs the entries queued and stops h.
+func (h *Hook) close() {
+	h.mutex.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.queue)
+	}
+	h.mutex.Unlock()
+
+	<-h.done
+}
+
+// fire_hooks hands e to the hooks of c, which may read it at once: the stack
+// is captured before the first of them gets it.
+func (c *core) fire_hooks(e *Entry) {
+	hooks, _ := c.hooks.Load().([]*Hook)
+	for _, h := range hooks {
+		if h.cfg.Stack && e.Level >= h.cfg.Level {
+			e.Stack = stack()
+			break
+		}
+	}
+
+	for _, h := range hooks {
+		if e.Level >= h.cfg.Level {
+			h.fire(e)
+		}
+	}
+}
+
+func (c *core) close_hooks() {
+	c.mutex.Lock()
+	hooks, _ := c.hooks.Load().([]*Hook)
+	c.hooks.Store([]*Hook(nil))
+	c.mutex.Unlock()
+
+	for _, h := range hooks {
+		h.close()
+	}
+}
+
+func stack() []byte {
+	buf := make([]byte, 4096)
+	for {
+		n := runtime.Stack(buf, false)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHooks(t *testing.T) {
+	l := NewLogger(&buffer_closer{})
+
+	var msgs []string
+	var stack []byte
+	l.AddHook(func(e *Entry) {
+		msgs = append(msgs, e.Level.String()+" "+e.Message)
+		stack = e.Stack
+	}, &HookConfig{Level: LevelError, Stack: true})
+
+	l.Info("info")
+	l.Error("error")
+	l.Critical("critical")
+	l.Close()
+
+	if strings.Join(msgs, "|") != "ERROR error|CRITICAL critical" {
+		t.Errorf("hook called with %v", msgs)
+	}
+	if !strings.Contains(string(stack), "TestHooks") {
+		t.Errorf("stack without the logging function:\n%s", stack)
+	}
+}
+
+func TestHookStackShared(t *testing.T) {
+	l := NewLogger(&buffer_closer{})
+
+	// the first hook gets the entry before the second one asks for the stack
+	stacks := make(chan []byte, 2)
+	l.AddHook(func(e *Entry) { stacks <- e.Stack }, nil)
+	l.AddHook(func(e *Entry) { stacks <- e.Stack }, &HookConfig{Stack: true})
+
+	l.Info("info")
+	l.Close()
+
+	for i := 0; i < 2; i++ {
+		if s := <-stacks; !strings.Contains(string(s), "TestHookStackShared") {
+			t.Errorf("hook %d stack without the logging function:\n%s", i, s)
+		}
+	}
+}
+
+func TestSlowHook(t *testing.T) {
+	l := NewSimpleLogger(&buffer_closer{})
+
+	chentered := make(chan bool, 10)
+	chrelease := make(chan bool)
+	n := 0
+	h := l.AddHook(func(e *Entry) {
+		chentered <- true
+		<-chrelease
+		n++
+	}, &HookConfig{QueueSize: 2})
+
+	l.Info("x")
+	<-chentered
+
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 9; i++ {
+			l.Info("x")
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("logging blocked by a slow hook")
+	}
+
+	close(chrelease)
+	l.Close()
+
+	// one entry taken by the hook, two queued, the others dropped
+	if n != 3 || h.Dropped() != 7 {
+		t.Errorf("hook ran %d times, dropped %d, want 3 and 7", n, h.Dropped())
+	}
+}
@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const default_journald_socket = "/run/systemd/journal/socket"
+
+type JournaldConfig struct {
+	// Socket defaults to /run/systemd/journal/socket.
+	Socket string
+	// Identifier is sent as SYSLOG_IDENTIFIER, it defaults to Entry.System,
+	// then to the program name.
+	Identifier string
+}
+
+// JournaldSink sends entries to systemd-journald using its native protocol.
+// Every entry is one datagram, so messages are limited by the socket buffer
+// size; passing large entries through a memfd is not supported.
+type JournaldSink struct {
+	cfg  JournaldConfig
+	conn *net.UnixConn
+	buf  []byte
+}
+
+func NewJournaldSink(cfg *JournaldConfig) (*JournaldSink, error) {
+	s := &JournaldSink{}
+	if cfg != nil {
+		s.cfg = *cfg
+	}
+	if s.cfg.Socket == "" {
+		s.cfg.Socket = default_journald_socket
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: s.cfg.Socket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	return s, nil
+}
+
+func (s *JournaldSink) WriteEntry(e *Entry) error {
+	ident := s.cfg.Identifier
+	if ident == "" {
+		ident = e.System
+	}
+	if ident == "" {
+		ident = filepath.Base(os.Args[0])
+	}
+
+	b := s.buf[:0]
+	b = append_journald_field(b, "MESSAGE", e.Message)
+	b = append_journald_field(b, "PRIORITY", strconv.Itoa(syslog_severity(e.Level)))
+	b = append_journald_field(b, "SYSLOG_IDENTIFIER", ident)
+	b = append_journald_field(b, "LOGGER_LEVEL", e.Level.String())
+	if e.Name != "" {
+		b = append_journald_field(b, "LOGGER_NAME", e.Name)
+	}
+	if e.Caller != "" {
+		b = append_journald_field(b, "LOGGER_CALLER", e.Caller)
+	}
+	for _, f := range e.Fields {
+		if key := journald_field_name(f.Key); key != "" {
+			b = append_journald_field(b, key, f.Value)
+		}
+	}
+	s.buf = b
+
+	_, err := s.conn.Write(b)
+	return err
+}
+
+// append_journald_field appends KEY=value, or the length prefixed binary
+// form when value spans several lines.
+func append_journald_field(b []byte, key, value string) []byte {
+	b = append(b, key...)
+	if !strings.ContainsRune(value, '\n') {
+		b = append(b, '=')
+		b = append(b, value...)
+		return append(b, '\n')
+	}
+
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	b = append(b, '\n')
+	b = append(b, size[:]...)
+	b = append(b, value...)
+	return append(b, '\n')
+}
+
+// journald_field_name turns key into a valid journal field name: upper case
+// letters, digits and underscores, not starting with an underscore.
+func journald_field_name(key string) string {
+	b := make([]byte, 0, len(key))
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+			b = append(b, c-'a'+'A')
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			b = append(b, c)
+		default:
+			b = append(b, '_')
+		}
+	}
+	return strings.TrimLeft(string(b), "_0123456789")
+}
+
+func (s *JournaldSink) Flush() error {
+	return nil
+}
+
+func (s *JournaldSink) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournaldSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journald")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s, err := NewJournaldSink(&JournaldConfig{Socket: socket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	e := &Entry{
+		Time:    time.Now(),
+		Level:   LevelWarn,
+		System:  "DEMO",
+		Message: "two\nlines",
+	}
+	if err := s.WriteEntry(e); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], 9)
+	want := "MESSAGE\n" + string(size[:]) + "two\nlines\n" +
+		"PRIORITY=4\n" +
+		"SYSLOG_IDENTIFIER=DEMO\n" +
+		"LOGGER_LEVEL=WARN\n"
+	if !bytes.Equal(buf[:n], []byte(want)) {
+		t.Errorf("got %q, want %q", buf[:n], want)
+	}
+}
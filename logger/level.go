@@ -0,0 +1,188 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// root_level_name stands for the logger's own level in LevelTree listings,
+// config files and the HTTP handler.
+const root_level_name = "*"
+
+// LevelTree holds the level overrides of named child loggers. A name such as
+// "dirdiff.walk" falls back to "dirdiff", then to the logger's own level.
+type LevelTree struct {
+	mutex sync.Mutex
+	names atomic.Value // map[string]Level, replaced on every change
+
+	get_root func() Level
+	set_root func(Level)
+}
+
+func new_level_tree(get_root func() Level, set_root func(Level)) *LevelTree {
+	t := &LevelTree{
+		get_root: get_root,
+		set_root: set_root,
+	}
+	t.names.Store(map[string]Level{})
+	return t
+}
+
+// Level returns the effective level of name.
+func (t *LevelTree) Level(name string) Level {
+	names := t.names.Load().(map[string]Level)
+	for name != "" && name != root_level_name {
+		if lv, ok := names[name]; ok {
+			return lv
+		}
+
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return t.get_root()
+}
+
+// SetLevel overrides the level of name and its children, "*" or "" sets
+// the logger's own level.
+func (t *LevelTree) SetLevel(name string, lv Level) {
+	if name == "" || name == root_level_name {
+		t.set_root(lv)
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	names := t.copy_names()
+	names[name] = lv
+	t.names.Store(names)
+}
+
+// Reset removes the override of name, it inherits its parent's level again.
+func (t *LevelTree) Reset(name string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	names := t.copy_names()
+	delete(names, name)
+	t.names.Store(names)
+}
+
+// Levels returns the overrides plus the logger's own level under "*".
+func (t *LevelTree) Levels() map[string]Level {
+	levels := t.copy_names()
+	levels[root_level_name] = t.get_root()
+	return levels
+}
+
+func (t *LevelTree) copy_names() map[string]Level {
+	old := t.names.Load().(map[string]Level)
+	names := make(map[string]Level, len(old)+1)
+	for k, v := range old {
+		names[k] = v
+	}
+	return names
+}
+
+// LoadFile replaces the overrides with the ones in file, a JSON object
+// mapping names to level names, such as {"*": "info", "dirdiff": "debug3"}.
+func (t *LevelTree) LoadFile(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var conf map[string]string
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+
+	names := make(map[string]Level, len(conf))
+	for name, s := range conf {
+		lv, err := ParseLevel(s)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %v", file, name, err)
+		}
+		names[name] = lv
+	}
+
+	if lv, ok := names[root_level_name]; ok {
+		t.set_root(lv)
+		delete(names, root_level_name)
+	}
+
+	t.mutex.Lock()
+	t.names.Store(names)
+	t.mutex.Unlock()
+	return nil
+}
+
+// ReloadOnSignal calls LoadFile(file) whenever one of sigs is received,
+// SIGHUP by default.
+func (t *LevelTree) ReloadOnSignal(file string, sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+
+	go func() {
+		for range ch {
+			if err := t.LoadFile(file); err != nil {
+				fmt.Fprintf(os.Stderr, "[logger] reload levels error: %v\n", err)
+			}
+		}
+	}()
+}
+
+// ServeHTTP lists the levels on GET, as the JSON object read by LoadFile.
+// PUT or POST with name and level parameters sets a level, DELETE with a
+// name parameter removes an override.
+func (t *LevelTree) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+
+	switch r.Method {
+	case "GET", "HEAD":
+
+	case "PUT", "POST":
+		lv, err := ParseLevel(r.FormValue("level"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		t.SetLevel(name, lv)
+
+	case "DELETE":
+		if name == "" || name == root_level_name {
+			http.Error(w, "cannot reset the root level", http.StatusBadRequest)
+			return
+		}
+		t.Reset(name)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	levels := t.Levels()
+	conf := make(map[string]string, len(levels))
+	for name, lv := range levels {
+		conf[name] = strings.ToLower(lv.String())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conf)
+}
@@ -0,0 +1,102 @@
+package logbus
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	pb "github.com/stormgbs/gopkg/protobuf"
+	"google.golang.org/grpc"
+)
+
+// Server is a reference LogBus server, appending the body of every request
+// to <root>/<from>/<path>.
+type Server struct {
+	root string
+
+	mutex sync.Mutex
+	files map[string]*os.File
+}
+
+func NewServer(root string) *Server {
+	return &Server{
+		root:  root,
+		files: make(map[string]*os.File),
+	}
+}
+
+func ListenAndServe(addr string, root string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	srv := NewServer(root)
+	defer srv.Close()
+
+	gs := grpc.NewServer()
+	pb.RegisterLogBusServer(gs, srv)
+	return gs.Serve(ln)
+}
+
+func (s *Server) LogMsgProcess(stream pb.LogBus_LogMsgProcessServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&pb.LogMsgReply{})
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.write(req); err != nil {
+			return stream.SendAndClose(&pb.LogMsgReply{Error: err.Error()})
+		}
+	}
+}
+
+// file_path maps a request to a file under root, ".." cannot escape it.
+func (s *Server) file_path(from, pth string) string {
+	return filepath.Join(s.root, filepath.Clean("/"+from), filepath.Clean("/"+pth))
+}
+
+func (s *Server) write(req *pb.LogMsgRequest) error {
+	name := s.file_path(req.From, req.Path)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	fp, ok := s.files[name]
+	if !ok {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return err
+		}
+
+		var err error
+		fp, err = os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0664)
+		if err != nil {
+			return err
+		}
+		s.files[name] = fp
+	}
+
+	_, err := fp.Write(req.Body)
+	return err
+}
+
+// Close closes every open file, they are reopened on the next request.
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var err error
+	for name, fp := range s.files {
+		if cerr := fp.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(s.files, name)
+	}
+	return err
+}
@@ -0,0 +1,243 @@
+// Package logbus ships logger entries to a LogBus server (pb/logbus.proto)
+// and provides a reference server writing them to files.
+//
+// The pb Go code is generated into github.com/stormgbs/gopkg/protobuf by pb/gen.sh.
+package logbus
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/stormgbs/gopkg/logger"
+	pb "github.com/stormgbs/gopkg/protobuf"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+const (
+	default_batch_bytes     = 64 * 1024
+	default_retry_interval  = 5 * time.Second
+	default_spool_max_bytes = 64 * 1024 * 1024
+	default_flush_timeout   = 10 * time.Second
+)
+
+var ErrUnavailable = errors.New("logbus server unavailable")
+
+type SinkConfig struct {
+	// Addr is the address of the LogBus server.
+	Addr string
+	// From identifies the sender, it defaults to os.Hostname().
+	From string
+	// Path is the file on the server the entries are appended to.
+	Path string
+	// Encoder defaults to logger.TextEncoder.
+	Encoder logger.Encoder
+	// SpoolDir keeps batches which could not be sent, they are resent in
+	// order once the server is back. Without it such batches are dropped.
+	SpoolDir string
+	// SpoolMaxBytes bounds the spool, 64MB by default. The oldest batches
+	// are dropped to make room for new ones.
+	SpoolMaxBytes int64
+	// BatchBytes is the size at which a batch is sent at once, 64KB by default.
+	BatchBytes int
+	// RetryInterval is the delay between attempts to reach the server, 5s by default.
+	RetryInterval time.Duration
+	// FlushTimeout bounds the wait for the server in a Flush, 10s by
+	// default. The Logger holds its lock meanwhile.
+	FlushTimeout time.Duration
+}
+
+// Sink is a logger.Sink sending every batch as one LogMsgRequest. Each
+// Flush opens a LogMsgProcess stream and waits for the server's reply, a
+// batch counts as delivered only once the reply carries no error. Failed
+// batches are spooled and resent whole, so a batch the server wrote before
+// failing on a later one is written again: delivery is at least once with
+// SpoolDir, at most once without.
+type Sink struct {
+	cfg SinkConfig
+
+	conn   *grpc.ClientConn
+	client pb.LogBusClient
+	ctx    context.Context // canceled by Close
+	cancel context.CancelFunc
+
+	buf        []byte
+	spool      *spool
+	dropped    int
+	next_retry time.Time
+}
+
+func NewSink(cfg *SinkConfig) (*Sink, error) {
+	conn, err := grpc.Dial(cfg.Addr, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := new_sink(pb.NewLogBusClient(conn), cfg)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	s.conn = conn
+	return s, nil
+}
+
+func new_sink(client pb.LogBusClient, cfg *SinkConfig) (*Sink, error) {
+	s := &Sink{
+		cfg:    *cfg,
+		client: client,
+	}
+
+	if s.cfg.From == "" {
+		s.cfg.From, _ = os.Hostname()
+	}
+	if s.cfg.Encoder == nil {
+		s.cfg.Encoder = logger.TextEncoder{}
+	}
+	if s.cfg.BatchBytes <= 0 {
+		s.cfg.BatchBytes = default_batch_bytes
+	}
+	if s.cfg.RetryInterval <= 0 {
+		s.cfg.RetryInterval = default_retry_interval
+	}
+
+	if s.cfg.FlushTimeout <= 0 {
+		s.cfg.FlushTimeout = default_flush_timeout
+	}
+	if s.cfg.SpoolMaxBytes <= 0 {
+		s.cfg.SpoolMaxBytes = default_spool_max_bytes
+	}
+
+	if s.cfg.SpoolDir != "" {
+		sp, err := new_spool(s.cfg.SpoolDir, s.cfg.SpoolMaxBytes)
+		if err != nil {
+			return nil, err
+		}
+		s.spool = sp
+	}
+
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s, nil
+}
+
+func (s *Sink) WriteEntry(e *logger.Entry) error {
+	s.buf = append(s.buf, s.cfg.Encoder.Encode(e)...)
+	if len(s.buf) >= s.cfg.BatchBytes {
+		return s.Flush()
+	}
+	return nil
+}
+
+// Flush sends the pending batch, after whatever is spooled. If the server
+// cannot be reached or reports an error the batch goes to the spool.
+func (s *Sink) Flush() error {
+	if len(s.buf) == 0 && s.spool.empty() {
+		return nil
+	}
+
+	err := s.send()
+	if err != nil && len(s.buf) > 0 {
+		if s.spool == nil {
+			s.dropped++
+		} else {
+			n, serr := s.spool.put(s.buf)
+			s.dropped += n
+			if serr != nil {
+				s.dropped++
+				err = serr
+			}
+		}
+	}
+
+	s.buf = s.buf[:0]
+	return err
+}
+
+// Dropped returns the number of batches lost so far, for want of a spool
+// or room in it.
+func (s *Sink) Dropped() int {
+	return s.dropped
+}
+
+// send delivers the spooled batches then the pending one over a new
+// stream. The spooled files are removed once the server acknowledged them.
+func (s *Sink) send() error {
+	if time.Now().Before(s.next_retry) {
+		return ErrUnavailable
+	}
+
+	var names []string
+	if !s.spool.empty() {
+		var err error
+		if names, err = s.spool.list(); err != nil {
+			return err
+		}
+	}
+
+	err := s.stream_batches(names)
+	if err != nil {
+		s.next_retry = time.Now().Add(s.cfg.RetryInterval)
+		return err
+	}
+
+	for _, name := range names {
+		s.spool.remove(name)
+	}
+	return nil
+}
+
+func (s *Sink) stream_batches(names []string) error {
+	ctx, cancel := context.WithTimeout(s.ctx, s.cfg.FlushTimeout)
+	defer cancel()
+
+	stream, err := s.client.LogMsgProcess(ctx)
+	if err != nil {
+		return err
+	}
+
+	// A Send error only tells the stream is gone, the reason comes with
+	// the reply.
+	serr := func() error {
+		for _, name := range names {
+			body, err := ioutil.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			if err := s.stream_send(stream, body); err != nil {
+				return err
+			}
+		}
+		if len(s.buf) == 0 {
+			return nil
+		}
+		return s.stream_send(stream, s.buf)
+	}()
+
+	reply, err := stream.CloseAndRecv()
+	if err == nil && reply.Error != "" {
+		err = errors.New(reply.Error)
+	}
+	if err == nil {
+		err = serr
+	}
+	return err
+}
+
+func (s *Sink) stream_send(stream pb.LogBus_LogMsgProcessClient, body []byte) error {
+	return stream.Send(&pb.LogMsgRequest{
+		From: s.cfg.From,
+		Path: s.cfg.Path,
+		Body: body,
+	})
+}
+
+func (s *Sink) Close() error {
+	err := s.Flush()
+	s.cancel()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	return err
+}
@@ -0,0 +1,253 @@
+package logbus
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stormgbs/gopkg/logger"
+	pb "github.com/stormgbs/gopkg/protobuf"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+var errDown = errors.New("down")
+
+// fake_client keeps the bodies of a stream once it is closed, unless fail
+// is set, which the reply then carries as a server error. With hang set the
+// reply never comes.
+type fake_client struct {
+	down   bool
+	fail   string
+	hang   bool
+	bodies []string
+}
+
+func (c *fake_client) LogMsgProcess(ctx context.Context, opts ...grpc.CallOption) (pb.LogBus_LogMsgProcessClient, error) {
+	if c.down {
+		return nil, errDown
+	}
+	return &fake_stream{c: c, ctx: ctx}, nil
+}
+
+type fake_stream struct {
+	grpc.ClientStream
+	c      *fake_client
+	ctx    context.Context
+	bodies []string
+}
+
+func (s *fake_stream) Send(req *pb.LogMsgRequest) error {
+	if s.c.down {
+		return errDown
+	}
+	if s.c.fail != "" {
+		return io.EOF
+	}
+	s.bodies = append(s.bodies, string(req.Body))
+	return nil
+}
+
+func (s *fake_stream) CloseSend() error {
+	return nil
+}
+
+func (s *fake_stream) CloseAndRecv() (*pb.LogMsgReply, error) {
+	if s.c.hang {
+		<-s.ctx.Done()
+		return nil, s.ctx.Err()
+	}
+	if s.c.down {
+		return nil, errDown
+	}
+	if s.c.fail != "" {
+		return &pb.LogMsgReply{Error: s.c.fail}, nil
+	}
+	s.c.bodies = append(s.c.bodies, s.bodies...)
+	return &pb.LogMsgReply{}, nil
+}
+
+func entry(msg string) *logger.Entry {
+	return &logger.Entry{Time: time.Now(), Level: logger.LevelInfo, Message: msg}
+}
+
+func TestSinkSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logbus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &fake_client{down: true}
+	s, err := new_sink(c, &SinkConfig{
+		Path:          "app.log",
+		Encoder:       encoder_func(func(e *logger.Entry) []byte { return []byte(e.Message) }),
+		SpoolDir:      dir,
+		RetryInterval: time.Nanosecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.WriteEntry(entry("a"))
+	if err := s.Flush(); err == nil {
+		t.Fatal("Flush succeeded with the server down")
+	}
+	s.WriteEntry(entry("b"))
+	s.Flush()
+
+	if names, _ := filepath.Glob(filepath.Join(dir, "*"+spool_suffix)); len(names) != 2 {
+		t.Fatalf("got %d spooled batches, want 2", len(names))
+	}
+
+	c.down = false
+	s.WriteEntry(entry("c"))
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.bodies) != 3 || c.bodies[0] != "a" || c.bodies[1] != "b" || c.bodies[2] != "c" {
+		t.Errorf("got bodies %q, want [a b c]", c.bodies)
+	}
+	if !s.spool.empty() {
+		t.Error("spool not emptied")
+	}
+}
+
+func TestSinkServerError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logbus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &fake_client{}
+	s, err := new_sink(c, &SinkConfig{
+		Path:          "app.log",
+		Encoder:       encoder_func(func(e *logger.Entry) []byte { return []byte(e.Message) }),
+		SpoolDir:      dir,
+		RetryInterval: time.Nanosecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.WriteEntry(entry("a"))
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	c.fail = "disk full"
+	s.WriteEntry(entry("b"))
+	if err := s.Flush(); err == nil || err.Error() != "disk full" {
+		t.Fatalf("Flush error %v, want disk full", err)
+	}
+	if s.spool.size != 1 {
+		t.Fatalf("got %d spooled batches, want 1", s.spool.size)
+	}
+
+	c.fail = ""
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.bodies) != 2 || c.bodies[0] != "a" || c.bodies[1] != "b" {
+		t.Errorf("got bodies %q, want [a b]", c.bodies)
+	}
+	if s.Dropped() != 0 {
+		t.Errorf("%d batches dropped", s.Dropped())
+	}
+}
+
+func TestSinkFlushTimeout(t *testing.T) {
+	c := &fake_client{hang: true}
+	s, err := new_sink(c, &SinkConfig{
+		Path:         "app.log",
+		FlushTimeout: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.WriteEntry(entry("a"))
+	start := time.Now()
+	if err := s.Flush(); err != context.DeadlineExceeded {
+		t.Errorf("Flush error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Flush took %v", d)
+	}
+	if s.Dropped() != 1 {
+		t.Errorf("%d batches dropped, want 1", s.Dropped())
+	}
+
+	s.Close()
+	if s.ctx.Err() == nil {
+		t.Error("Close did not cancel the streams")
+	}
+}
+
+func TestSpoolLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logbus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &fake_client{down: true}
+	s, err := new_sink(c, &SinkConfig{
+		Path:          "app.log",
+		Encoder:       encoder_func(func(e *logger.Entry) []byte { return []byte(e.Message) }),
+		SpoolDir:      dir,
+		SpoolMaxBytes: 8,
+		RetryInterval: time.Nanosecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, msg := range []string{"aaa", "bbb", "ccc", "ddddddddd"} {
+		s.WriteEntry(entry(msg))
+		s.Flush()
+	}
+	if s.Dropped() != 2 {
+		t.Errorf("%d batches dropped, want 2", s.Dropped())
+	}
+
+	// A restart sees what is left.
+	sp, err := new_spool(dir, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sp.size != 2 || sp.bytes != 6 {
+		t.Errorf("spool holds %d batches of %d bytes, want 2 of 6", sp.size, sp.bytes)
+	}
+
+	c.down = false
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.bodies) != 2 || c.bodies[0] != "bbb" || c.bodies[1] != "ccc" {
+		t.Errorf("got bodies %q, want [bbb ccc]", c.bodies)
+	}
+}
+
+type encoder_func func(e *logger.Entry) []byte
+
+func (f encoder_func) Encode(e *logger.Entry) []byte { return f(e) }
+
+func TestServerFilePath(t *testing.T) {
+	s := NewServer("/var/log/logbus")
+	for _, c := range []struct{ From, Path, Want string }{
+		{"host1", "app/app.log", "/var/log/logbus/host1/app/app.log"},
+		{"../..", "../../etc/passwd", "/var/log/logbus/etc/passwd"},
+		{"", "/app.log", "/var/log/logbus/app.log"},
+	} {
+		if got := s.file_path(c.From, c.Path); got != c.Want {
+			t.Errorf("file_path(%q, %q) = %q, want %q", c.From, c.Path, got, c.Want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package logbus
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const spool_suffix = ".spool"
+
+// spool keeps batches which could not be sent, one file per batch, named so
+// that sorting by name gives the order they were logged in. It holds at
+// most max_bytes, dropping the oldest batches beyond.
+type spool struct {
+	dir       string
+	seq       int64
+	size      int
+	bytes     int64
+	max_bytes int64
+}
+
+func new_spool(dir string, max_bytes int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	sp := &spool{dir: dir, max_bytes: max_bytes}
+	names, err := sp.list()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if fi, err := os.Stat(name); err == nil {
+			sp.bytes += fi.Size()
+		}
+	}
+	sp.size = len(names)
+	return sp, nil
+}
+
+func (sp *spool) empty() bool {
+	return sp == nil || sp.size == 0
+}
+
+// put adds body to the spool and returns how many batches were dropped to
+// make room for it. A body larger than max_bytes is dropped itself.
+func (sp *spool) put(body []byte) (int, error) {
+	if int64(len(body)) > sp.max_bytes {
+		return 1, nil
+	}
+
+	dropped := 0
+	if sp.bytes+int64(len(body)) > sp.max_bytes {
+		names, err := sp.list()
+		if err != nil {
+			return 0, err
+		}
+		for _, name := range names {
+			if sp.bytes+int64(len(body)) <= sp.max_bytes {
+				break
+			}
+			if err := sp.remove(name); err != nil {
+				return dropped, err
+			}
+			dropped++
+		}
+	}
+
+	seq := time.Now().UnixNano()
+	if seq <= sp.seq {
+		seq = sp.seq + 1
+	}
+	sp.seq = seq
+
+	name := filepath.Join(sp.dir, fmt.Sprintf("%020d%s", seq, spool_suffix))
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, body, 0644); err != nil {
+		os.Remove(tmp)
+		return dropped, err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return dropped, err
+	}
+	sp.size++
+	sp.bytes += int64(len(body))
+	return dropped, nil
+}
+
+// list returns the spooled files, oldest first.
+func (sp *spool) list() ([]string, error) {
+	fis, err := ioutil.ReadDir(sp.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, fi := range fis {
+		if fi.Mode().IsRegular() && strings.HasSuffix(fi.Name(), spool_suffix) {
+			names = append(names, filepath.Join(sp.dir, fi.Name()))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (sp *spool) remove(name string) error {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil {
+		return err
+	}
+	sp.size--
+	sp.bytes -= fi.Size()
+	return nil
+}
@@ -1,63 +1,133 @@
 package logger
 
 import (
-	"fmt"
+	"errors"
 	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-type Logger struct {
-	mutex sync.Mutex
+var ErrLoggerClosed = errors.New("logger closed")
 
-	disable bool
+const (
+	default_buffer_size        = 200000
+	default_sample_rate        = 100
+	default_drop_warn_interval = 10 * time.Second
+	default_batch_bytes        = 64 * 1024
+	batch_max_entries          = 4096
+)
 
-	w io.WriteCloser
-	// bw                 *bufio.Writer
-	time_format        string
-	level              Level
-	enable_caller_info bool
-	caller_path_number int
+type LoggerConfig struct {
+	// BufferSize is the capacity of the buffer channel, 200000 by default.
+	BufferSize int
+	// Overflow is the policy applied when the buffer is full.
+	Overflow OverflowPolicy
+	// SampleRate is used by OverflowSample, 100 by default.
+	SampleRate int
+	// DropWarnInterval is how often a warning line reporting dropped entries
+	// is written, 10s by default.
+	DropWarnInterval time.Duration
+	// BatchBytes is the size at which the writer sink writes its batch at
+	// once, 64KB by default.
+	BatchBytes int
+	// FlushInterval, when set, delays writing a batch by up to this long to
+	// gather more entries. By default a batch is written as soon as the
+	// buffer runs empty.
+	FlushInterval time.Duration
+	// Sinks are written to in addition to the writer given to the constructor.
+	Sinks []SinkConfig
+}
 
-	logbuf chan []byte
+type Logger struct {
+	core
+
+	// primary is the sink of the writer given to the constructor, the one
+	// SetWriter and SetEncoder act on.
+	primary *WriterSink
+	sinks   []sink_entry
+
+	logbuf  chan *Entry
+	chflush chan chan error
+	chexit  chan bool
+	wg      sync.WaitGroup
+	closed  int32
+
+	overflow           OverflowPolicy
+	sample_rate        uint64
+	sample_count       uint64
+	dropped            uint64
+	reported           uint64
+	drop_warn_interval time.Duration
+	last_report        time.Time
+
+	batch_bytes    int
+	flush_interval time.Duration
 }
 
 func NewDefaultLogger() *Logger {
-	l := &Logger{
-		w: os.Stdout,
-		// bw:                 bufio.NewWriter(os.Stdout),
-		time_format:        default_time_format,
-		level:              LevelDebug,
-		enable_caller_info: true,
-		caller_path_number: 3,
-
-		logbuf: make(chan []byte, 200000),
-	}
-	go l.loop_write()
+	l := new_logger(os.Stdout, nil)
+	l.enable_caller_info = true
 	return l
 }
 
 func NewLogger(wc io.WriteCloser) *Logger {
+	return new_logger(wc, nil)
+}
+
+// NewLoggerWithConfig creates a Logger writing to wc, if not nil, and to
+// the sinks of cfg.
+func NewLoggerWithConfig(wc io.WriteCloser, cfg *LoggerConfig) *Logger {
+	return new_logger(wc, cfg)
+}
+
+func new_logger(wc io.WriteCloser, cfg *LoggerConfig) *Logger {
+	var c LoggerConfig
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = default_buffer_size
+	}
+	if c.SampleRate <= 0 {
+		c.SampleRate = default_sample_rate
+	}
+	if c.DropWarnInterval <= 0 {
+		c.DropWarnInterval = default_drop_warn_interval
+	}
+	if c.BatchBytes <= 0 {
+		c.BatchBytes = default_batch_bytes
+	}
+
 	l := &Logger{
-		w: wc,
-		// bw:          bufio.NewWriter(wc),
-		time_format:        default_time_format,
-		level:              LevelDebug,
-		caller_path_number: 3,
+		logbuf:  make(chan *Entry, c.BufferSize),
+		chflush: make(chan chan error),
+		chexit:  make(chan bool),
 
-		logbuf: make(chan []byte, 200000),
+		overflow:           c.Overflow,
+		sample_rate:        uint64(c.SampleRate),
+		drop_warn_interval: c.DropWarnInterval,
+		last_report:        time.Now(),
+
+		batch_bytes:    c.BatchBytes,
+		flush_interval: c.FlushInterval,
 	}
-	go l.loop_write()
-	return l
-}
 
-func (l *Logger) Enable() {
-	l.disable = false
-}
+	if wc != nil {
+		l.primary = NewWriterSink(wc, default_encoder)
+		l.primary.batch_bytes = c.BatchBytes
+		l.sinks = append(l.sinks, sink_entry{l.primary, LevelDebug4})
+	}
+	for _, sc := range c.Sinks {
+		l.sinks = append(l.sinks, sink_entry{sc.Sink, sc.Level})
+	}
+
+	l.core.init(l.output)
 
-func (l *Logger) Disable() {
-	l.disable = true
+	l.wg.Add(1)
+	go l.loop_write()
+	return l
 }
 
 func (l *Logger) SetWriter(w io.WriteCloser) {
@@ -66,188 +136,194 @@ func (l *Logger) SetWriter(w io.WriteCloser) {
 	}
 
 	l.mutex.Lock()
-	old_w := l.w
-	// old_bw := l.bw
-	l.w = w
-	// l.bw = bufio.NewWriterSize(w, 1024*1024)
+	if l.primary == nil {
+		l.primary = NewWriterSink(w, l.encoder)
+		l.primary.batch_bytes = l.batch_bytes
+		l.sinks = append([]sink_entry{{l.primary, LevelDebug4}}, l.sinks...)
+		l.mutex.Unlock()
+		return
+	}
+
+	l.primary.Flush()
+	old_w := l.primary.w
+	l.primary.w = w
+	l.primary.encoder = resolve_encoder(l.encoder, w)
 	l.mutex.Unlock()
 
-	// if old_w != nil && old_bw != nil {
 	if old_w != nil && old_w != os.Stdout && old_w != os.Stderr {
-		// old_bw.Flush()
-		// old_bw = nil
 		old_w.Close()
 		old_w = nil
-		// old_bw = nil
 	}
 }
 
-func (l *Logger) SetLevel(lv Level) {
-	l.level = lv
-}
+// AddSink makes l also write entries at lv and above to s. Entries still
+// have to pass the level set by SetLevel first.
+func (l *Logger) AddSink(s Sink, lv Level) {
+	if s == nil {
+		panic("AddSink s is null")
+	}
 
-func (l *Logger) SetTimeFormat(format string) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
-	l.time_format = format
+	l.sinks = append(l.sinks, sink_entry{s, lv})
 }
 
-func (l *Logger) EnableCallerInfo() {
-	l.enable_caller_info = true
-}
+func (l *Logger) SetEncoder(enc Encoder) {
+	if enc == nil {
+		panic("SetEncoder enc is null")
+	}
 
-func (l *Logger) DisableCallerInfo() {
-	l.enable_caller_info = false
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.encoder = enc
+	if l.primary != nil {
+		l.primary.encoder = resolve_encoder(enc, l.primary.w)
+	}
 }
 
-func (l *Logger) write(level_str string, format string, a ...interface{}) {
-	var s string
-
-	if !l.enable_caller_info {
-		s = fmt.Sprintf(time.Now().Format(l.time_format)+" ["+level_str+"] "+format+"\n", a...)
-	} else {
-		s = fmt.Sprintf(time.Now().Format(l.time_format)+" ["+level_str+"] ["+get_caller_info(l.caller_path_number).String()+"] "+format+"\n", a...)
+func (l *Logger) output(e *Entry) {
+	if atomic.LoadInt32(&l.closed) == 1 {
+		return
 	}
 
-	l.logbuf <- StringToReadonlySlice(&s)
-	// l.logbuf <- []byte(s)
-	// l.mutex.Lock()
-	// n, err := l.bw.Write([]byte(s))
-	// l.mutex.Unlock()
-	// log.Printf("xxxlog.Info %p, %p,  %t %v (%d:%v)", l.bw, l.w, l.disable, l.level, n, err)
+	l.enqueue(e)
 }
 
+// loop_write blocks on the buffer channel and hands entries to the sinks in
+// batches: the entries available at once are dispatched together and the
+// sinks are flushed when the channel runs empty, or once flush_interval has
+// passed if it is set.
 func (l *Logger) loop_write() {
-	var count int
+	defer l.wg.Done()
 
-	for {
-		count = 0
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	armed := false
 
-		l.mutex.Lock()
-	innerfor:
-		for {
-			select {
-			case logentry := <-l.logbuf:
-				count++
-				// l.bw.Write(logentry)
-				l.w.Write(logentry)
-				if count > 1000 {
-					l.mutex.Unlock()
-					time.Sleep(time.Millisecond)
-					break innerfor
+	for {
+		select {
+		case e := <-l.logbuf:
+			l.mutex.Lock()
+			l.dispatch(e)
+			l.collect()
+			l.mutex.Unlock()
+
+			if l.flush_interval > 0 {
+				if !armed {
+					timer.Reset(l.flush_interval)
+					armed = true
 				}
-			default:
-				l.mutex.Unlock()
-				time.Sleep(time.Millisecond * 100)
-				break innerfor
+				continue
 			}
-		}
 
-	}
-}
+		case <-timer.C:
 
-func (l *Logger) Debug4(format string, a ...interface{}) {
-	if l.disable {
-		return
-	}
-	if l.level <= LevelDebug4 {
-		l.write("DEBUG4", format, a...)
-	}
-}
+		case ch := <-l.chflush:
+			l.mutex.Lock()
+			l.drain(len(l.logbuf))
+			ch <- l.flush_sinks()
+			l.mutex.Unlock()
 
-func (l *Logger) Debug3(format string, a ...interface{}) {
-	if l.disable {
-		return
-	}
-	if l.level <= LevelDebug3 {
-		l.write("DEBUG3", format, a...)
-	}
-}
+		case <-l.chexit:
+			l.mutex.Lock()
+			l.drain(len(l.logbuf))
+			for _, e := range l.sample_flush(l.sampling()) {
+				l.dispatch(e)
+			}
+			l.report_dropped(time.Now())
+			l.flush_sinks()
+			l.mutex.Unlock()
+			return
+		}
 
-func (l *Logger) Debug2(format string, a ...interface{}) {
-	if l.disable {
-		return
-	}
-	if l.level <= LevelDebug2 {
-		l.write("DEBUG2", format, a...)
+		if armed {
+			timer.Stop()
+			armed = false
+		}
+
+		l.mutex.Lock()
+		if now := time.Now(); now.Sub(l.last_report) >= l.drop_warn_interval {
+			l.report_dropped(now)
+		}
+		l.flush_sinks()
+		l.mutex.Unlock()
 	}
 }
 
-func (l *Logger) Debug1(format string, a ...interface{}) {
-	if l.disable {
-		return
-	}
-	if l.level <= LevelDebug1 {
-		l.write("DEBUG1", format, a...)
+// dispatch hands e to every sink whose level it reaches. The caller must hold l.mutex.
+func (l *Logger) dispatch(e *Entry) {
+	for _, se := range l.sinks {
+		if e.Level >= se.level {
+			se.sink.WriteEntry(e)
+		}
 	}
 }
 
-func (l *Logger) Debug(format string, a ...interface{}) {
-	if l.disable {
-		return
-	}
-	if l.level <= LevelDebug {
-		l.write("DEBUG", format, a...)
+// collect dispatches entries already waiting in the channel, without
+// blocking, up to batch_max_entries. The caller must hold l.mutex.
+func (l *Logger) collect() {
+	for i := 0; i < batch_max_entries; i++ {
+		select {
+		case e := <-l.logbuf:
+			l.dispatch(e)
+		default:
+			return
+		}
 	}
 }
 
-func (l *Logger) Info(format string, a ...interface{}) {
-	if l.disable {
-		return
-	}
-	if l.level <= LevelInfo {
-		l.write("INFO", format, a...)
+// drain dispatches n queued entries. The caller must hold l.mutex.
+func (l *Logger) drain(n int) {
+	for i := 0; i < n; i++ {
+		l.dispatch(<-l.logbuf)
 	}
 }
 
-func (l *Logger) Warn(format string, a ...interface{}) {
-	if l.disable {
-		return
-	}
-	if l.level <= LevelWarn {
-		l.write("WARN", format, a...)
+// flush_sinks flushes every sink, returning the first error. The caller
+// must hold l.mutex.
+func (l *Logger) flush_sinks() error {
+	var err error
+	for _, se := range l.sinks {
+		if ferr := se.sink.Flush(); ferr != nil && err == nil {
+			err = ferr
+		}
 	}
+	return err
 }
 
-func (l *Logger) Error(format string, a ...interface{}) {
-	if l.disable {
-		return
+// Flush blocks until every entry queued before the call has been written.
+func (l *Logger) Flush() error {
+	if atomic.LoadInt32(&l.closed) == 1 {
+		return ErrLoggerClosed
 	}
-	if l.level <= LevelError {
-		l.write("ERROR", format, a...)
+
+	ch := make(chan error, 1)
+	select {
+	case l.chflush <- ch:
+		return <-ch
+	case <-l.chexit:
+		return ErrLoggerClosed
 	}
 }
 
-func (l *Logger) Critical(format string, a ...interface{}) {
-	if l.disable {
+// Close stops the background writer after draining the queued entries,
+// then closes the sinks. Entries logged after Close are dropped.
+func (l *Logger) Close() {
+	if !atomic.CompareAndSwapInt32(&l.closed, 0, 1) {
 		return
 	}
-	if l.level <= LevelCrit {
-		l.write("CRITICAL", format, a...)
-	}
-}
 
-func (l *Logger) Flush() error {
-	return nil
-	// l.mutex.Lock()
-	// var err error
-	// if l.bw != nil {
-	// 	err = l.bw.Flush()
-	// } else {
-	// 	err = ErrEmptyLogWriter
-	// }
-	// l.mutex.Unlock()
-	// return err
-}
+	close(l.chexit)
+	l.wg.Wait()
+	l.close_hooks()
 
-func (l *Logger) Close() {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
-	// l.bw.Flush()
+	// entries raced in between the closed check in write and loop exit
+	l.drain(len(l.logbuf))
 
-	w := l.w
-	if w != nil && w != os.Stdout && w != os.Stderr {
-		w.Close()
+	for _, se := range l.sinks {
+		se.sink.Close()
 	}
 }
@@ -0,0 +1,173 @@
+package logger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"testing"
+	"time"
+)
+
+// polling_writer reproduces the loop_write design replaced by the batched
+// writer: poll the channel, sleep 100ms when it is empty and 1ms every 1000
+// entries, one Write per entry.
+type polling_writer struct {
+	logbuf chan []byte
+	w      func([]byte)
+}
+
+func new_polling_writer(w func([]byte)) *polling_writer {
+	p := &polling_writer{logbuf: make(chan []byte, default_buffer_size), w: w}
+	go p.loop()
+	return p
+}
+
+func (p *polling_writer) loop() {
+	for {
+		count := 0
+	innerfor:
+		for {
+			select {
+			case logentry := <-p.logbuf:
+				count++
+				p.w(logentry)
+				if count > 1000 {
+					time.Sleep(time.Millisecond)
+					break innerfor
+				}
+			default:
+				time.Sleep(time.Millisecond * 100)
+				break innerfor
+			}
+		}
+	}
+}
+
+type func_writer func([]byte)
+
+func (f func_writer) Write(p []byte) (int, error) { f(p); return len(p), nil }
+func (f func_writer) Close() error                { return nil }
+
+type func_encoder func(*Entry) []byte
+
+func (f func_encoder) Encode(e *Entry) []byte { return f(e) }
+
+var bench_line = []byte("2017/07/28 17:14:36.928 [INFO] benchmark line with some payload\n")
+
+func BenchmarkThroughputPolling(b *testing.B) {
+	done := make(chan bool)
+	n := 0
+	p := new_polling_writer(func(e []byte) {
+		ioutil.Discard.Write(e)
+		if n++; n == b.N {
+			close(done)
+		}
+	})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.logbuf <- bench_line
+	}
+	<-done
+}
+
+func BenchmarkThroughputBatched(b *testing.B) {
+	done := make(chan bool)
+	n := 0
+	l := NewLogger(func_writer(func(e []byte) {
+		ioutil.Discard.Write(e)
+		if n += len(e) / len(bench_line); n == b.N {
+			close(done)
+		}
+	}))
+	defer l.Close()
+
+	e := &Entry{Message: "benchmark line with some payload"}
+	l.primary.encoder = func_encoder(func(*Entry) []byte { return bench_line })
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.enqueue(e)
+	}
+	<-done
+}
+
+// latency logs one entry at a time and measures how long it takes to reach
+// the writer, reporting the median and 99th percentile.
+func latency(b *testing.B, enqueue func(), written chan bool) {
+	ds := make([]time.Duration, b.N)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		enqueue()
+		<-written
+		ds[i] = time.Since(start)
+	}
+	b.StopTimer()
+
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	b.ReportMetric(float64(ds[len(ds)/2].Nanoseconds()), "p50-ns")
+	b.ReportMetric(float64(ds[len(ds)*99/100].Nanoseconds()), "p99-ns")
+}
+
+func BenchmarkLatencyPolling(b *testing.B) {
+	written := make(chan bool)
+	p := new_polling_writer(func([]byte) { written <- true })
+	latency(b, func() { p.logbuf <- bench_line }, written)
+}
+
+func BenchmarkLatencyBatched(b *testing.B) {
+	written := make(chan bool)
+	l := NewLogger(func_writer(func([]byte) { written <- true }))
+	defer l.Close()
+
+	e := &Entry{Message: "benchmark line with some payload"}
+	l.primary.encoder = func_encoder(func(*Entry) []byte { return bench_line })
+	latency(b, func() { l.enqueue(e) }, written)
+}
+
+// legacy_output reproduces the formatting of the SimpleLogger write path
+// replaced by the encoders: one fmt.Sprintf over a concatenated format.
+func legacy_output(w func([]byte), system, format string, a ...interface{}) {
+	s := fmt.Sprintf(time.Now().Format(default_time_format)+" [INFO] |"+system+"| "+format+"\n", a...)
+	w([]byte(s))
+}
+
+func BenchmarkLineLegacy(b *testing.B) {
+	w := func([]byte) {}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		legacy_output(w, "DEMO", "request %d done", i)
+	}
+}
+
+func BenchmarkLineSimpleLogger(b *testing.B) {
+	l := NewSimpleLogger(func_writer(func([]byte) {}))
+	l.System = "DEMO"
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Info("request %d done", i)
+	}
+}
+
+func BenchmarkAppendEntry(b *testing.B) {
+	e := &Entry{
+		Time:       time.Now(),
+		TimeFormat: default_time_format,
+		Level:      LevelInfo,
+		System:     "DEMO",
+		Caller:     "main:demo.go:main(..):35",
+		Message:    "request 1 done",
+	}
+
+	for _, enc := range []AppendEncoder{TextEncoder{}, JSONEncoder{}, LogfmtEncoder{}, ConsoleEncoder{}} {
+		b.Run(fmt.Sprintf("%T", enc), func(b *testing.B) {
+			buf := make([]byte, 0, 512)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf = enc.AppendEntry(buf[:0], e)
+			}
+		})
+	}
+}
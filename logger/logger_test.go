@@ -0,0 +1,180 @@
+package logger
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+type buffer_closer struct {
+	mutex  sync.Mutex
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (b *buffer_closer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *buffer_closer) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.closed = true
+	return nil
+}
+
+func (b *buffer_closer) lines() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return bytes.Count(b.buf.Bytes(), []byte("\n"))
+}
+
+func TestLoggerFlush(t *testing.T) {
+	w := &buffer_closer{}
+	l := NewLogger(w)
+	defer l.Close()
+
+	for i := 0; i < 5000; i++ {
+		l.Info("line %d", i)
+	}
+
+	if err := l.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n := w.lines(); n != 5000 {
+		t.Errorf("got %d lines after Flush, want 5000", n)
+	}
+}
+
+func TestLoggerClose(t *testing.T) {
+	w := &buffer_closer{}
+	l := NewLogger(w)
+
+	for i := 0; i < 5000; i++ {
+		l.Info("line %d", i)
+	}
+	l.Close()
+
+	if !w.closed {
+		t.Error("writer not closed")
+	}
+	if n := w.lines(); n != 5000 {
+		t.Errorf("got %d lines after Close, want 5000", n)
+	}
+
+	l.Info("dropped")
+	if err := l.Flush(); err != ErrLoggerClosed {
+		t.Errorf("Flush after Close returned %v", err)
+	}
+	l.Close()
+}
+
+type blocking_writer struct {
+	buffer_closer
+	chentered chan bool
+	chunblock chan bool
+}
+
+func new_blocking_writer() *blocking_writer {
+	return &blocking_writer{
+		chentered: make(chan bool, 1),
+		chunblock: make(chan bool),
+	}
+}
+
+func (b *blocking_writer) Write(p []byte) (int, error) {
+	select {
+	case b.chentered <- true:
+	default:
+	}
+	<-b.chunblock
+	return b.buffer_closer.Write(p)
+}
+
+func TestLoggerOverflowDropNewest(t *testing.T) {
+	w := new_blocking_writer()
+	l := NewLoggerWithConfig(w, &LoggerConfig{BufferSize: 10, Overflow: OverflowDropNewest})
+
+	l.Info("first")
+	<-w.chentered
+
+	for i := 0; i < 100; i++ {
+		l.Info("line %d", i)
+	}
+	if d := l.Dropped(); d != 90 {
+		t.Errorf("dropped %d entries, want 90", d)
+	}
+
+	close(w.chunblock)
+	l.Close()
+
+	if !bytes.Contains(w.buf.Bytes(), []byte("entries dropped")) {
+		t.Errorf("no dropped warning in output:\n%s", w.buf.Bytes())
+	}
+}
+
+func TestLoggerOverflowDropOldest(t *testing.T) {
+	w := new_blocking_writer()
+	l := NewLoggerWithConfig(w, &LoggerConfig{BufferSize: 10, Overflow: OverflowDropOldest})
+
+	l.Info("first")
+	<-w.chentered
+
+	for i := 0; i < 100; i++ {
+		l.Info("line %d", i)
+	}
+
+	close(w.chunblock)
+	l.Close()
+
+	if !bytes.Contains(w.buf.Bytes(), []byte("line 99\n")) {
+		t.Errorf("newest entry missing from output:\n%s", w.buf.Bytes())
+	}
+}
+
+func TestLoggerSinks(t *testing.T) {
+	stdout := &buffer_closer{}
+	file := &buffer_closer{}
+	errfile := &buffer_closer{}
+
+	l := NewLoggerWithConfig(nil, &LoggerConfig{
+		Sinks: []SinkConfig{
+			{NewWriterSink(stdout, TextEncoder{}), LevelInfo},
+			{NewWriterSink(file, JSONEncoder{}), LevelDebug2},
+		},
+	})
+	l.AddSink(NewWriterSink(errfile, LogfmtEncoder{}), LevelError)
+	l.SetLevel(LevelDebug2)
+
+	l.Debug3("debug3")
+	l.Debug2("debug2")
+	l.Info("info")
+	l.Error("error")
+	l.Critical("critical")
+	l.Close()
+
+	for _, c := range []struct {
+		W     *buffer_closer
+		Lines int
+	}{
+		{stdout, 3},
+		{file, 4},
+		{errfile, 2},
+	} {
+		if n := c.W.lines(); n != c.Lines {
+			t.Errorf("got %d lines, want %d:\n%s", n, c.Lines, c.W.buf.Bytes())
+		}
+		if !c.W.closed {
+			t.Error("sink not closed")
+		}
+	}
+
+	if !bytes.HasPrefix(file.buf.Bytes(), []byte(`{"time":`)) {
+		t.Errorf("file sink not JSON encoded:\n%s", file.buf.Bytes())
+	}
+	if !bytes.Contains(errfile.buf.Bytes(), []byte("level=critical")) {
+		t.Errorf("error sink not logfmt encoded:\n%s", errfile.buf.Bytes())
+	}
+}
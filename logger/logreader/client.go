@@ -0,0 +1,72 @@
+package logreader
+
+import (
+	pb "github.com/stormgbs/gopkg/protobuf"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// Client calls a LogReader server.
+type Client struct {
+	conn   *grpc.ClientConn
+	client pb.LogReaderClient
+}
+
+func NewClient(addr string) (*Client, error) {
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		conn:   conn,
+		client: pb.NewLogReaderClient(conn),
+	}, nil
+}
+
+// DirTree returns the entries under the directory pth of the server.
+func (c *Client) DirTree(ctx context.Context, pth string) ([]*pb.Entry, error) {
+	reply, err := c.client.DirTree(ctx, &pb.ReadDirectoryRequest{Path: pth})
+	if err != nil {
+		return nil, err
+	}
+	return reply.Entries, nil
+}
+
+// ReadFile returns the last lines lines of file, or all of it if lines is 0.
+func (c *Client) ReadFile(ctx context.Context, file string, lines int) ([]byte, error) {
+	reply, err := c.client.ReadFile(ctx, &pb.ReadFileRequest{File: file, Lines: int64(lines)})
+	if err != nil {
+		return nil, err
+	}
+	return reply.Body, nil
+}
+
+// FollowFile calls fn with every line appended to file and the offset past
+// it, see Server.FollowFile, until ctx is done or fn returns an error.
+func (c *Client) FollowFile(ctx context.Context, file string, offset int64, lines int, fn func(line []byte, offset int64) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.client.FollowFile(ctx, &pb.FollowFileRequest{File: file, Offset: offset, Lines: int64(lines)})
+	if err != nil {
+		return err
+	}
+
+	for {
+		reply, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if err := fn(reply.Line, reply.Offset); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
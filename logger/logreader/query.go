@@ -0,0 +1,147 @@
+package logreader
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/stormgbs/gopkg/logger"
+	"golang.org/x/net/context"
+)
+
+// Query selects entries of log files written by logger's TextEncoder or
+// JSONEncoder. Zero fields match everything.
+type Query struct {
+	// From and To bound the entry time, From included and To excluded.
+	From time.Time
+	To   time.Time
+	// Level is the lowest level matched.
+	Level logger.Level
+	// CallerPackage matches the package of the caller, by its full import
+	// path or its last elements, "dirdiff" matching
+	// "github.com/stormgbs/gopkg/dirdiff".
+	CallerPackage string
+	// Message matches the message.
+	Message *regexp.Regexp
+	// TimeFormat is the time layout of the logger, logger's default if empty.
+	TimeFormat string
+}
+
+func (q *Query) Match(e *logger.Entry) bool {
+	if !q.From.IsZero() && e.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !e.Time.Before(q.To) {
+		return false
+	}
+	if e.Level < q.Level {
+		return false
+	}
+	if q.CallerPackage != "" {
+		pkg := e.CallerPackage()
+		if pkg != q.CallerPackage && !strings.HasSuffix(pkg, "/"+q.CallerPackage) {
+			return false
+		}
+	}
+	if q.Message != nil && !q.Message.MatchString(e.Message) {
+		return false
+	}
+	return true
+}
+
+// SearchRotated is Search over file and the files rotated from it by
+// logger.RotateWriter, oldest first. Files last modified before q.From are
+// skipped.
+func SearchRotated(ctx context.Context, file string, q *Query, fn func(file string, e *logger.Entry) error) error {
+	files, err := logger.Backups(file)
+	if err != nil {
+		return err
+	}
+	files = append(files, file)
+
+	if !q.From.IsZero() {
+		recent := files[:0]
+		for _, f := range files {
+			if finfo, err := os.Stat(f); err == nil && finfo.ModTime().Before(q.From) {
+				continue
+			}
+			recent = append(recent, f)
+		}
+		files = recent
+	}
+	return Search(ctx, files, q, fn)
+}
+
+// Search calls fn with the entries of files matching q, in order, reading
+// files ending in .gz through gzip. Lines which do not parse, such as the
+// rest of a multi-line message, are appended to the message of the entry
+// before them.
+func Search(ctx context.Context, files []string, q *Query, fn func(file string, e *logger.Entry) error) error {
+	for _, file := range files {
+		if err := search_file(ctx, file, q, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func search_file(ctx context.Context, file string, q *Query, fn func(file string, e *logger.Entry) error) error {
+	fp, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	var r io.Reader = fp
+	if strings.HasSuffix(file, ".gz") {
+		gzr, err := gzip.NewReader(fp)
+		if err != nil {
+			return err
+		}
+		defer gzr.Close()
+		r = gzr
+	}
+
+	var last *logger.Entry
+	flush := func() error {
+		if last == nil || !q.Match(last) {
+			return nil
+		}
+		return fn(file, last)
+	}
+
+	br := bufio.NewReaderSize(r, 64*1024)
+	for n := 0; ; n++ {
+		if n%1024 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		line, rerr := br.ReadBytes('\n')
+		if len(line) > 0 {
+			e, err := logger.ParseLine(line, q.TimeFormat)
+			if err != nil {
+				if last != nil {
+					last.Message += "\n" + strings.TrimRight(string(line), "\r\n")
+				}
+			} else {
+				if err := flush(); err != nil {
+					return err
+				}
+				last = e
+			}
+		}
+
+		if rerr == io.EOF {
+			return flush()
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package logreader
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stormgbs/gopkg/logger"
+	"golang.org/x/net/context"
+)
+
+const query_log_old = `2017/07/28 10:00:00.000 [INFO] [github.com/stormgbs/gopkg/dirdiff:diff.go:DiffDirs(..):10] old diff
+`
+
+const query_log_backup = `2017/07/28 11:00:00.000 [ERROR] [github.com/stormgbs/gopkg/consul:consul.go:Get(..):20] Get(a) error
+2017/07/28 11:30:00.000 [DEBUG] [github.com/stormgbs/gopkg/dirdiff:diff.go:DiffDirs(..):10] diff 1
+`
+
+const query_log_current = `{"time":"2017/07/28 12:00:00.000","level":"WARN","caller":"github.com/stormgbs/gopkg/dirdiff:tar.go:Tar(..):5","msg":"tar slow"}
+2017/07/28 12:30:00.000 [CRITICAL] [github.com/stormgbs/gopkg/dirdiff:diff.go:DiffDirs(..):12] panic
+goroutine 1 [running]:
+main.main()
+`
+
+func TestSearchRotated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "query")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "app.log")
+	old := file + ".20170728-110000.gz"
+	backup := file + ".20170728-120000"
+
+	fp, _ := os.Create(old)
+	gzw := gzip.NewWriter(fp)
+	gzw.Write([]byte(query_log_old))
+	gzw.Close()
+	fp.Close()
+	ioutil.WriteFile(backup, []byte(query_log_backup), 0644)
+	ioutil.WriteFile(file, []byte(query_log_current), 0644)
+
+	base := time.Now().Add(-time.Hour)
+	os.Chtimes(old, base, base)
+	os.Chtimes(backup, base.Add(time.Minute), base.Add(time.Minute))
+
+	search := func(q *Query) []string {
+		var msgs []string
+		err := SearchRotated(context.Background(), file, q, func(f string, e *logger.Entry) error {
+			msgs = append(msgs, filepath.Base(f)+":"+e.Message)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msgs
+	}
+
+	cases := []struct {
+		q    Query
+		want string
+	}{
+		{Query{CallerPackage: "dirdiff"},
+			"app.log.20170728-110000.gz:old diff|app.log.20170728-120000:diff 1|app.log:tar slow|app.log:panic\ngoroutine 1 [running]:\nmain.main()"},
+		{Query{Level: logger.LevelWarn},
+			"app.log.20170728-120000:Get(a) error|app.log:tar slow|app.log:panic\ngoroutine 1 [running]:\nmain.main()"},
+		{Query{From: time.Date(2017, 7, 28, 11, 0, 0, 0, time.Local), To: time.Date(2017, 7, 28, 12, 0, 0, 0, time.Local)},
+			"app.log.20170728-120000:Get(a) error|app.log.20170728-120000:diff 1"},
+		{Query{Message: regexp.MustCompile(`^Get\(`), CallerPackage: "github.com/stormgbs/gopkg/consul"},
+			"app.log.20170728-120000:Get(a) error"},
+	}
+	for i, c := range cases {
+		if got := strings.Join(search(&c.q), "|"); got != c.want {
+			t.Errorf("case %d:\n got %q\nwant %q", i, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+// Package logreader implements the LogReader service of pb/logfile.proto:
+// browsing the directory tree under a root, reading the end of files and
+// following them. It also searches the files written by logger.
+//
+// The pb Go code is generated into github.com/stormgbs/gopkg/protobuf by pb/gen.sh.
+package logreader
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	pb "github.com/stormgbs/gopkg/protobuf"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+const (
+	modify_time_format    = "2006-01-02 15:04:05"
+	default_max_file_size = 16 << 20
+)
+
+var (
+	ErrOutsideRoot  = errors.New("path outside root")
+	ErrNotRegular   = errors.New("not a regular file")
+	ErrFileTooLarge = errors.New("file too large, read its last lines instead")
+)
+
+type ServerConfig struct {
+	// Root is the directory served, paths of requests are relative to it.
+	Root string
+	// MaxDepth limits how deep DirTree descends, 0 means no limit.
+	MaxDepth int
+	// MaxFileSize is the largest file ReadFile returns whole, when asked
+	// for 0 lines, 16MB by default.
+	MaxFileSize int64
+	// PollInterval is how often FollowFile checks the file, see TailConfig.
+	PollInterval time.Duration
+}
+
+// Server serves the files under a root directory. Paths in requests and in
+// Entry.AbsName are rooted at it, "/" being the root itself, and neither
+// ".." nor symbolic links lead out of it.
+type Server struct {
+	cfg  ServerConfig
+	root string
+
+	mutex  sync.Mutex
+	users  map[uint32]string
+	groups map[uint32]string
+}
+
+func NewServer(cfg *ServerConfig) (*Server, error) {
+	root, err := filepath.Abs(cfg.Root)
+	if err != nil {
+		return nil, err
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		cfg:    *cfg,
+		root:   root,
+		users:  make(map[uint32]string),
+		groups: make(map[uint32]string),
+	}
+	if s.cfg.MaxFileSize <= 0 {
+		s.cfg.MaxFileSize = default_max_file_size
+	}
+	return s, nil
+}
+
+func ListenAndServe(addr string, cfg *ServerConfig) error {
+	srv, err := NewServer(cfg)
+	if err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	gs := grpc.NewServer()
+	pb.RegisterLogReaderServer(gs, srv)
+	return gs.Serve(ln)
+}
+
+// DirTree returns the entries of the directory req.Path, with those of its
+// subdirectories. Symbolic links are listed but not followed.
+func (s *Server) DirTree(ctx context.Context, req *pb.ReadDirectoryRequest) (*pb.ReadDirectoryReply, error) {
+	name, err := s.resolve(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.read_dir(ctx, name, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ReadDirectoryReply{Entries: entries}, nil
+}
+
+// ReadFile returns the last req.Lines lines of req.File, or the whole file
+// if req.Lines is 0.
+func (s *Server) ReadFile(ctx context.Context, req *pb.ReadFileRequest) (*pb.ReadFileReply, error) {
+	name, err := s.resolve(req.File)
+	if err != nil {
+		return nil, err
+	}
+
+	fp, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	finfo, err := fp.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !finfo.Mode().IsRegular() {
+		return nil, ErrNotRegular
+	}
+
+	var body []byte
+	if req.Lines > 0 {
+		body, err = tail_lines(fp, finfo.Size(), int(req.Lines))
+	} else if finfo.Size() > s.cfg.MaxFileSize {
+		err = ErrFileTooLarge
+	} else {
+		body, err = ioutil.ReadAll(fp)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ReadFileReply{Body: body}, nil
+}
+
+// FollowFile streams the lines appended to req.File, going on through its
+// rotations, until the client goes away. It starts with the last req.Lines
+// lines if set, from req.Offset otherwise, -1 being the end of the file.
+func (s *Server) FollowFile(req *pb.FollowFileRequest, stream pb.LogReader_FollowFileServer) error {
+	name, err := s.resolve(req.File)
+	if err != nil {
+		return err
+	}
+
+	offset := req.Offset
+	if req.Lines > 0 {
+		if offset, err = tail_offset(name, int(req.Lines)); err != nil {
+			return err
+		}
+	}
+
+	t, err := NewTailer(name, &TailConfig{Offset: offset, PollInterval: s.cfg.PollInterval})
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case line, ok := <-t.Lines():
+			if !ok {
+				return t.Err()
+			}
+			if err := stream.Send(&pb.FollowFileReply{Line: line.Text, Offset: line.Offset}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// tail_offset returns the offset of the last n lines of file.
+func tail_offset(file string, n int) (int64, error) {
+	fp, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer fp.Close()
+
+	finfo, err := fp.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	body, err := tail_lines(fp, finfo.Size(), n)
+	if err != nil {
+		return 0, err
+	}
+	return finfo.Size() - int64(len(body)), nil
+}
+
+// resolve maps a request path to a file under root. ".." cannot climb above
+// root, and the path is refused if a symbolic link takes it elsewhere.
+func (s *Server) resolve(pth string) (string, error) {
+	name := filepath.Join(s.root, filepath.Clean("/"+pth))
+
+	real, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return "", err
+	}
+	if real != s.root && !strings.HasPrefix(real, s.root+string(filepath.Separator)) {
+		return "", ErrOutsideRoot
+	}
+	return real, nil
+}
+
+// rel returns the path of name as seen by clients.
+func (s *Server) rel(name string) string {
+	r, err := filepath.Rel(s.root, name)
+	if err != nil {
+		return "/"
+	}
+	return filepath.ToSlash(filepath.Join("/", r))
+}
+
+func (s *Server) read_dir(ctx context.Context, dir string, depth int) ([]*pb.Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	finfos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*pb.Entry, 0, len(finfos))
+	for _, finfo := range finfos {
+		name := filepath.Join(dir, finfo.Name())
+		e := s.new_entry(name, finfo)
+
+		if e.Type == pb.FileType_Dir && (s.cfg.MaxDepth <= 0 || depth < s.cfg.MaxDepth) {
+			// unreadable directories are listed without their entries
+			sub, err := s.read_dir(ctx, name, depth+1)
+			if err != nil && ctx.Err() != nil {
+				return nil, err
+			}
+			e.Entries = sub
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (s *Server) new_entry(name string, finfo os.FileInfo) *pb.Entry {
+	e := &pb.Entry{
+		Name:       finfo.Name(),
+		AbsName:    s.rel(name),
+		Size:       finfo.Size(),
+		ModifyTime: finfo.ModTime().Format(modify_time_format),
+		Mode:       finfo.Mode().String(),
+	}
+
+	switch mode := finfo.Mode(); {
+	case mode.IsRegular():
+		e.Type = pb.FileType_Reg
+	case mode.IsDir():
+		e.Type = pb.FileType_Dir
+	case mode&os.ModeSymlink != 0:
+		e.Type = pb.FileType_Symlink
+		e.LinkTarget, _ = os.Readlink(name)
+	default:
+		e.Type = pb.FileType_Other
+	}
+
+	if st, ok := finfo.Sys().(*syscall.Stat_t); ok {
+		e.User, e.Group = s.owner(st.Uid, st.Gid)
+	}
+	return e
+}
+
+// owner returns the user and group names of uid and gid, or the numbers
+// when they have no name.
+func (s *Server) owner(uid, gid uint32) (string, string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	u, ok := s.users[uid]
+	if !ok {
+		u = strconv.FormatUint(uint64(uid), 10)
+		if usr, err := user.LookupId(u); err == nil {
+			u = usr.Username
+		}
+		s.users[uid] = u
+	}
+
+	g, ok := s.groups[gid]
+	if !ok {
+		g = strconv.FormatUint(uint64(gid), 10)
+		if grp, err := user.LookupGroupId(g); err == nil {
+			g = grp.Name
+		}
+		s.groups[gid] = g
+	}
+	return u, g
+}
@@ -0,0 +1,133 @@
+package logreader
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/stormgbs/gopkg/protobuf"
+	"golang.org/x/net/context"
+)
+
+func new_test_server(t *testing.T) (*Server, string) {
+	root, err := ioutil.TempDir("", "logreader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.MkdirAll(filepath.Join(root, "app", "old"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "app", "app.log"), []byte("1\n2\n3\n"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "app", "old", "app.log.1"), []byte("0\n"), 0644)
+	os.Symlink("app/app.log", filepath.Join(root, "current.log"))
+	os.Symlink("/etc", filepath.Join(root, "etc"))
+
+	s, err := NewServer(&ServerConfig{Root: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, root
+}
+
+func TestDirTree(t *testing.T) {
+	s, root := new_test_server(t)
+	defer os.RemoveAll(root)
+
+	reply, err := s.DirTree(context.Background(), &pb.ReadDirectoryRequest{Path: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make(map[string]*pb.Entry)
+	var walk func([]*pb.Entry)
+	walk = func(entries []*pb.Entry) {
+		for _, e := range entries {
+			names[e.AbsName] = e
+			walk(e.Entries)
+		}
+	}
+	walk(reply.Entries)
+
+	if len(names) != 6 {
+		t.Errorf("got %d entries, want 6: %v", len(names), names)
+	}
+	if e := names["/app/old/app.log.1"]; e == nil || e.Type != pb.FileType_Reg || e.Size != 2 || e.User == "" {
+		t.Errorf("bad nested entry: %+v", e)
+	}
+	if e := names["/etc"]; e == nil || e.Type != pb.FileType_Symlink || e.LinkTarget != "/etc" || len(e.Entries) != 0 {
+		t.Errorf("bad symlink entry: %+v", e)
+	}
+
+	s.cfg.MaxDepth = 1
+	reply, _ = s.DirTree(context.Background(), &pb.ReadDirectoryRequest{Path: "app"})
+	for _, e := range reply.Entries {
+		if len(e.Entries) != 0 {
+			t.Errorf("MaxDepth 1 descended into %s", e.AbsName)
+		}
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	s, root := new_test_server(t)
+	defer os.RemoveAll(root)
+
+	ctx := context.Background()
+	cases := []struct {
+		file  string
+		lines int64
+		want  string
+	}{
+		{"/app/app.log", 2, "2\n3\n"},
+		{"/app/app.log", 10, "1\n2\n3\n"},
+		{"/app/app.log", 0, "1\n2\n3\n"},
+		{"/current.log", 1, "3\n"},
+		{"/../../app/app.log", 1, "3\n"},
+	}
+	for _, c := range cases {
+		reply, err := s.ReadFile(ctx, &pb.ReadFileRequest{File: c.file, Lines: c.lines})
+		if err != nil || string(reply.Body) != c.want {
+			t.Errorf("ReadFile(%s, %d) = %v, %q, want %q", c.file, c.lines, err, reply, c.want)
+		}
+	}
+
+	if _, err := s.ReadFile(ctx, &pb.ReadFileRequest{File: "/etc/passwd"}); err != ErrOutsideRoot {
+		t.Errorf("read through a symlink out of root: %v", err)
+	}
+	if _, err := s.ReadFile(ctx, &pb.ReadFileRequest{File: "/app"}); err != ErrNotRegular {
+		t.Errorf("read a directory: %v", err)
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	var buf bytes.Buffer
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(&buf, "line %d\n", i)
+	}
+	data := buf.Bytes()
+
+	cases := []struct {
+		data []byte
+		n    int
+		want string
+	}{
+		{data, 3, "line 99997\nline 99998\nline 99999\n"},
+		{data[:len(data)-1], 2, "line 99998\nline 99999"},
+		{[]byte("a\nb"), 5, "a\nb"},
+		{[]byte("\n\n"), 1, "\n"},
+		{nil, 1, ""},
+	}
+	for _, c := range cases {
+		got, err := tail_lines(bytes.NewReader(c.data), int64(len(c.data)), c.n)
+		if err != nil || string(got) != c.want {
+			t.Errorf("tail_lines(%d) = %v, %q, want %q", c.n, err, got, c.want)
+		}
+	}
+
+	// spanning several chunks
+	got, _ := tail_lines(bytes.NewReader(data), int64(len(data)), 20000)
+	if want := data[bytes.Index(data, []byte("line 80000\n")):]; !bytes.Equal(got, want) {
+		t.Errorf("tail over chunks: got %d bytes, want %d", len(got), len(want))
+	}
+}
@@ -0,0 +1,66 @@
+package logreader
+
+import (
+	"bytes"
+	"io"
+)
+
+const tail_chunk_size = 64 * 1024
+
+// tail_lines returns the last n lines of the first size bytes of r, reading
+// it backwards by chunks so that only the end of a large file is read. A
+// last line without its newline counts as a line.
+func tail_lines(r io.ReaderAt, size int64, n int) ([]byte, error) {
+	if n <= 0 || size == 0 {
+		return nil, nil
+	}
+
+	var (
+		chunks [][]byte
+		total  int
+		end    = size
+		found  = 0
+	)
+
+	for end > 0 {
+		start := end - tail_chunk_size
+		if start < 0 {
+			start = 0
+		}
+
+		chunk := make([]byte, end-start)
+		if _, err := r.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		// the newline ending the file does not start a line
+		search := chunk
+		if end == size && chunk[len(chunk)-1] == '\n' {
+			search = chunk[:len(chunk)-1]
+		}
+
+		for i := len(search) - 1; i >= 0; i-- {
+			if search[i] != '\n' {
+				continue
+			}
+			if found++; found == n {
+				chunks = append(chunks, chunk[i+1:])
+				return join_reversed(chunks, total+len(chunk)-i-1), nil
+			}
+		}
+
+		chunks = append(chunks, chunk)
+		total += len(chunk)
+		end = start
+	}
+	return join_reversed(chunks, total), nil
+}
+
+func join_reversed(chunks [][]byte, total int) []byte {
+	var buf bytes.Buffer
+	buf.Grow(total)
+	for i := len(chunks) - 1; i >= 0; i-- {
+		buf.Write(chunks[i])
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,241 @@
+package logreader
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const default_poll_interval = 250 * time.Millisecond
+
+type TailConfig struct {
+	// Offset is where reading starts, -1 being the end of the file. An
+	// offset past the end, the file having been truncated or replaced since
+	// it was saved, starts from the beginning.
+	Offset int64
+	// PollInterval is how often the file is checked for new data, rotation
+	// and truncation, 250ms by default.
+	PollInterval time.Duration
+}
+
+// Line is a line read by a Tailer, without its newline.
+type Line struct {
+	Text []byte
+	// Offset is the position just past the line in the file it was read
+	// from, to resume from after a restart.
+	Offset int64
+}
+
+// Tailer follows a file like tail -F: it sends every line appended to it on
+// a channel and goes on with the new file when the file is rotated, moved
+// aside and replaced by another one, or from the beginning if it is
+// truncated.
+type Tailer struct {
+	file string
+	cfg  TailConfig
+
+	fp      *os.File
+	finfo   os.FileInfo
+	offset  int64
+	partial []byte
+	err     error
+
+	lines     chan Line
+	chexit    chan bool
+	wg        sync.WaitGroup
+	exit_once sync.Once
+}
+
+func NewTailer(file string, cfg *TailConfig) (*Tailer, error) {
+	t := &Tailer{
+		file:   file,
+		lines:  make(chan Line, 64),
+		chexit: make(chan bool),
+	}
+	if cfg != nil {
+		t.cfg = *cfg
+	}
+	if t.cfg.PollInterval <= 0 {
+		t.cfg.PollInterval = default_poll_interval
+	}
+
+	if err := t.open(t.cfg.Offset); err != nil {
+		return nil, err
+	}
+
+	t.wg.Add(1)
+	go t.loop()
+	return t, nil
+}
+
+// Lines returns the channel of lines read, closed by Close or after an
+// error reported by Err.
+func (t *Tailer) Lines() <-chan Line {
+	return t.lines
+}
+
+// Err returns the error which stopped t, once Lines is closed.
+func (t *Tailer) Err() error {
+	return t.err
+}
+
+func (t *Tailer) Close() error {
+	t.exit_once.Do(func() {
+		close(t.chexit)
+	})
+	t.wg.Wait()
+	return nil
+}
+
+func (t *Tailer) open(offset int64) error {
+	fp, err := os.Open(t.file)
+	if err != nil {
+		return err
+	}
+
+	finfo, err := fp.Stat()
+	if err != nil {
+		fp.Close()
+		return err
+	}
+	if !finfo.Mode().IsRegular() {
+		fp.Close()
+		return ErrNotRegular
+	}
+
+	if offset < 0 {
+		offset = finfo.Size()
+	} else if offset > finfo.Size() {
+		offset = 0
+	}
+	if _, err := fp.Seek(offset, io.SeekStart); err != nil {
+		fp.Close()
+		return err
+	}
+
+	if t.fp != nil {
+		t.fp.Close()
+	}
+	t.fp, t.finfo, t.offset = fp, finfo, offset
+	t.partial = t.partial[:0]
+	return nil
+}
+
+func (t *Tailer) loop() {
+	defer t.wg.Done()
+	defer close(t.lines)
+	defer func() {
+		t.fp.Close()
+	}()
+
+	buf := make([]byte, 32*1024)
+	for {
+		ok, err := t.read(buf)
+		if err != nil {
+			t.err = err
+			return
+		}
+		if !ok {
+			return
+		}
+
+		select {
+		case <-t.chexit:
+			return
+		case <-time.After(t.cfg.PollInterval):
+		}
+
+		if !t.check(buf) {
+			return
+		}
+	}
+}
+
+// read sends the lines available up to the end of the file. It returns
+// false if t is closed meanwhile.
+func (t *Tailer) read(buf []byte) (bool, error) {
+	for {
+		n, err := t.fp.Read(buf)
+		if n > 0 && !t.split(buf[:n]) {
+			return false, nil
+		}
+		if err == io.EOF || (err == nil && n == 0) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+func (t *Tailer) split(data []byte) bool {
+	base := t.offset
+	t.offset += int64(len(data))
+
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			t.partial = append(t.partial, data...)
+			return true
+		}
+
+		text := make([]byte, len(t.partial)+i)
+		copy(text, t.partial)
+		copy(text[len(t.partial):], data[:i])
+		t.partial = t.partial[:0]
+
+		base += int64(i) + 1
+		if !t.send(Line{Text: text, Offset: base}) {
+			return false
+		}
+		data = data[i+1:]
+	}
+	return true
+}
+
+func (t *Tailer) send(line Line) bool {
+	select {
+	case t.lines <- line:
+		return true
+	case <-t.chexit:
+		return false
+	}
+}
+
+// check looks for rotation and truncation of the file once the end of it
+// has been reached. It returns false if t is closed meanwhile.
+func (t *Tailer) check(buf []byte) bool {
+	finfo, err := os.Stat(t.file)
+	if err != nil {
+		// moved aside and not created again yet
+		return true
+	}
+
+	if !os.SameFile(finfo, t.finfo) {
+		// lines may have reached the old file after the last read
+		if ok, err := t.read(buf); !ok && err == nil {
+			return false
+		}
+		if len(t.partial) > 0 {
+			text := append([]byte(nil), t.partial...)
+			t.partial = t.partial[:0]
+			if !t.send(Line{Text: text, Offset: t.offset}) {
+				return false
+			}
+		}
+
+		// retried at the next poll if it fails
+		t.open(0)
+		return true
+	}
+
+	if finfo.Size() < t.offset {
+		if _, err := t.fp.Seek(0, io.SeekStart); err == nil {
+			t.offset = 0
+			t.partial = t.partial[:0]
+		}
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package logreader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "github.com/stormgbs/gopkg/protobuf"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func append_file(t *testing.T, name, data string) {
+	fp, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp.WriteString(data)
+	fp.Close()
+}
+
+func expect_lines(t *testing.T, ch <-chan Line, want ...string) Line {
+	var last Line
+	for _, w := range want {
+		select {
+		case line, ok := <-ch:
+			if !ok {
+				t.Fatalf("lines closed, want %q", w)
+			}
+			if string(line.Text) != w {
+				t.Fatalf("got %q, want %q", line.Text, w)
+			}
+			last = line
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for %q", w)
+		}
+	}
+	return last
+}
+
+func TestTailer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tailer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "app.log")
+	append_file(t, name, "a\nb\n")
+
+	tl, err := NewTailer(name, &TailConfig{PollInterval: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect_lines(t, tl.Lines(), "a", "b")
+
+	append_file(t, name, "c")
+	append_file(t, name, "d\n")
+	if line := expect_lines(t, tl.Lines(), "cd"); line.Offset != 7 {
+		t.Errorf("offset %d, want 7", line.Offset)
+	}
+
+	// rotated, the last line of the old file written after the rename
+	os.Rename(name, name+".1")
+	append_file(t, name+".1", "e\n")
+	append_file(t, name, "f\n")
+	expect_lines(t, tl.Lines(), "e", "f")
+
+	// truncated
+	os.Truncate(name, 0)
+	time.Sleep(50 * time.Millisecond)
+	append_file(t, name, "g\n")
+	expect_lines(t, tl.Lines(), "g")
+
+	tl.Close()
+	if _, ok := <-tl.Lines(); ok {
+		t.Errorf("lines not closed")
+	}
+
+	// resumed from a saved offset
+	append_file(t, name, "h\n")
+	tl, err = NewTailer(name, &TailConfig{Offset: 2, PollInterval: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect_lines(t, tl.Lines(), "h")
+	tl.Close()
+}
+
+type follow_stream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	replies chan *pb.FollowFileReply
+}
+
+func (s *follow_stream) Context() context.Context { return s.ctx }
+
+func (s *follow_stream) Send(r *pb.FollowFileReply) error {
+	s.replies <- r
+	return nil
+}
+
+func TestFollowFile(t *testing.T) {
+	s, root := new_test_server(t)
+	defer os.RemoveAll(root)
+	s.cfg.PollInterval = 5 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &follow_stream{ctx: ctx, replies: make(chan *pb.FollowFileReply, 10)}
+
+	cherr := make(chan error)
+	go func() {
+		cherr <- s.FollowFile(&pb.FollowFileRequest{File: "/app/app.log", Lines: 1}, stream)
+	}()
+
+	for _, want := range []string{"3", "4"} {
+		select {
+		case r := <-stream.replies:
+			if string(r.Line) != want {
+				t.Errorf("got %q, want %q", r.Line, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for %q", want)
+		}
+		if want == "3" {
+			append_file(t, filepath.Join(root, "app", "app.log"), "4\n")
+		}
+	}
+
+	cancel()
+	if err := <-cherr; err != context.Canceled {
+		t.Errorf("FollowFile returned %v", err)
+	}
+}
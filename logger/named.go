@@ -0,0 +1,77 @@
+package logger
+
+import "fmt"
+
+// NamedLogger is a child logger created by Named or FromContext. It writes
+// through its root logger, with its own level looked up in the root's
+// LevelTree, adding its fields to every entry.
+type NamedLogger struct {
+	name   string
+	c      *core
+	fields []Field
+}
+
+func (n *NamedLogger) Name() string {
+	return n.name
+}
+
+// Named returns a grandchild logger, called "<n's name>.<name>".
+func (n *NamedLogger) Named(name string) *NamedLogger {
+	if n.name != "" {
+		name = n.name + "." + name
+	}
+	return &NamedLogger{name: name, c: n.c, fields: n.fields}
+}
+
+// With returns a copy of n adding key=value to every entry.
+func (n *NamedLogger) With(key string, value interface{}) *NamedLogger {
+	fields := make([]Field, len(n.fields), len(n.fields)+1)
+	copy(fields, n.fields)
+	return &NamedLogger{name: n.name, c: n.c, fields: append(fields, Field{key, fmt.Sprint(value)})}
+}
+
+func (n *NamedLogger) SetLevel(lv Level) {
+	n.c.levels.SetLevel(n.name, lv)
+}
+
+// Output is like the level methods, reporting the caller skip frames above
+// the code calling Output.
+func (n *NamedLogger) Output(skip int, level Level, format string, a ...interface{}) {
+	n.c.log(3+skip, n.name, n.fields, level, format, a...)
+}
+
+func (n *NamedLogger) Debug4(format string, a ...interface{}) {
+	n.c.log(3, n.name, n.fields, LevelDebug4, format, a...)
+}
+
+func (n *NamedLogger) Debug3(format string, a ...interface{}) {
+	n.c.log(3, n.name, n.fields, LevelDebug3, format, a...)
+}
+
+func (n *NamedLogger) Debug2(format string, a ...interface{}) {
+	n.c.log(3, n.name, n.fields, LevelDebug2, format, a...)
+}
+
+func (n *NamedLogger) Debug1(format string, a ...interface{}) {
+	n.c.log(3, n.name, n.fields, LevelDebug1, format, a...)
+}
+
+func (n *NamedLogger) Debug(format string, a ...interface{}) {
+	n.c.log(3, n.name, n.fields, LevelDebug, format, a...)
+}
+
+func (n *NamedLogger) Info(format string, a ...interface{}) {
+	n.c.log(3, n.name, n.fields, LevelInfo, format, a...)
+}
+
+func (n *NamedLogger) Warn(format string, a ...interface{}) {
+	n.c.log(3, n.name, n.fields, LevelWarn, format, a...)
+}
+
+func (n *NamedLogger) Error(format string, a ...interface{}) {
+	n.c.log(3, n.name, n.fields, LevelError, format, a...)
+}
+
+func (n *NamedLogger) Critical(format string, a ...interface{}) {
+	n.c.log(3, n.name, n.fields, LevelCrit, format, a...)
+}
@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNamedLevels(t *testing.T) {
+	w := &buffer_closer{}
+	l := NewSimpleLogger(w)
+	l.SetLevel(LevelInfo)
+	l.EnableCallerInfo()
+
+	dd := l.Named("dirdiff")
+	walk := dd.Named("walk")
+	l.Levels().SetLevel("dirdiff", LevelDebug3)
+
+	l.Debug3("root")
+	dd.Debug3("dirdiff")
+	walk.Debug3("walk")
+	walk.Debug4("walk4")
+	l.Named("consul").Debug3("consul")
+
+	out := w.buf.String()
+	if strings.Contains(out, "root") || strings.Contains(out, "consul") || strings.Contains(out, "walk4") {
+		t.Errorf("entries below their level written:\n%s", out)
+	}
+	if !strings.Contains(out, "{dirdiff} [") || !strings.Contains(out, "{dirdiff.walk} [") {
+		t.Errorf("named entries missing:\n%s", out)
+	}
+	if n := strings.Count(out, "named_test.go:TestNamedLevels"); n != 2 {
+		t.Errorf("got %d lines with the caller of the test, want 2:\n%s", n, out)
+	}
+
+	l.Levels().Reset("dirdiff")
+	w.buf.Reset()
+	walk.Debug3("walk")
+	if w.buf.Len() != 0 {
+		t.Errorf("override still active after Reset:\n%s", w.buf.Bytes())
+	}
+}
+
+func TestLevelTreeHandler(t *testing.T) {
+	l := NewSimpleLogger(&buffer_closer{})
+	h := l.Levels()
+
+	r := httptest.NewRequest("PUT", "/?name=dirdiff&level=debug3", nil)
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	if rw.Code != 200 || strings.TrimSpace(rw.Body.String()) != `{"*":"debug","dirdiff":"debug3"}` {
+		t.Errorf("PUT: %d %s", rw.Code, rw.Body.Bytes())
+	}
+
+	r = httptest.NewRequest("PUT", "/?name=*&level=warn", nil)
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	if l.root_level() != LevelWarn {
+		t.Errorf("root level %v, want WARN", l.root_level())
+	}
+
+	r = httptest.NewRequest("PUT", "/?name=x&level=loud", nil)
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	if rw.Code != 400 {
+		t.Errorf("bad level accepted: %d", rw.Code)
+	}
+
+	r = httptest.NewRequest("DELETE", "/?name=dirdiff", nil)
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	if !bytes.Equal(bytes.TrimSpace(rw.Body.Bytes()), []byte(`{"*":"warn"}`)) {
+		t.Errorf("DELETE: %d %s", rw.Code, rw.Body.Bytes())
+	}
+}
+
+func TestLevelTreeLoadFile(t *testing.T) {
+	fp, err := ioutil.TempFile("", "levels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fp.WriteString(`{"*": "error", "dirdiff": "debug2"}`)
+	fp.Close()
+
+	l := NewSimpleLogger(&buffer_closer{})
+	l.Levels().SetLevel("consul", LevelDebug4)
+	if err := l.Levels().LoadFile(fp.Name()); err != nil {
+		t.Fatal(err)
+	}
+
+	if lv := l.Levels().Level("dirdiff.walk"); lv != LevelDebug2 {
+		t.Errorf("dirdiff.walk level %v, want DEBUG2", lv)
+	}
+	if lv := l.Levels().Level("consul"); lv != LevelError {
+		t.Errorf("consul level %v, want ERROR", lv)
+	}
+}
+
+func TestRootLevelConcurrent(t *testing.T) {
+	l := NewSimpleLogger(&buffer_closer{})
+	defer l.Close()
+
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			l.Levels().SetLevel("*", LevelInfo)
+			l.Levels().SetLevel("*", LevelWarn)
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		l.Info("info")
+	}
+	<-done
+}
@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy decides what Logger.write does when the buffer channel is full.
+type OverflowPolicy uint8
+
+const (
+	// OverflowBlock waits for room in the buffer.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest discards the entry being logged.
+	OverflowDropNewest
+	// OverflowDropOldest discards the oldest queued entry to make room.
+	OverflowDropOldest
+	// OverflowSample keeps one of every SampleRate entries, blocking for it,
+	// and discards the others.
+	OverflowSample
+)
+
+func StringToOverflowPolicy(s string) OverflowPolicy {
+	switch s {
+	case "drop", "drop_newest", "drop-newest":
+		return OverflowDropNewest
+	case "drop_oldest", "drop-oldest":
+		return OverflowDropOldest
+	case "sample":
+		return OverflowSample
+	default:
+		return OverflowBlock
+	}
+}
+
+// Dropped returns how many entries have been discarded because the buffer was full.
+func (l *Logger) Dropped() uint64 {
+	return atomic.LoadUint64(&l.dropped)
+}
+
+func (l *Logger) enqueue(e *Entry) {
+	switch l.overflow {
+	case OverflowDropNewest:
+		select {
+		case l.logbuf <- e:
+		default:
+			atomic.AddUint64(&l.dropped, 1)
+		}
+
+	case OverflowDropOldest:
+		for {
+			select {
+			case l.logbuf <- e:
+				return
+			default:
+			}
+
+			select {
+			case <-l.logbuf:
+				atomic.AddUint64(&l.dropped, 1)
+			default:
+			}
+		}
+
+	case OverflowSample:
+		select {
+		case l.logbuf <- e:
+		default:
+			if atomic.AddUint64(&l.sample_count, 1)%l.sample_rate == 0 {
+				l.logbuf <- e
+			} else {
+				atomic.AddUint64(&l.dropped, 1)
+			}
+		}
+
+	default:
+		l.logbuf <- e
+	}
+}
+
+// report_dropped writes a warning line if entries were dropped since the last
+// report. The caller must hold l.mutex.
+func (l *Logger) report_dropped(now time.Time) {
+	l.last_report = now
+
+	dropped := atomic.LoadUint64(&l.dropped)
+	if dropped == l.reported {
+		return
+	}
+
+	e := Entry{
+		Time:       now,
+		TimeFormat: l.time_format,
+		Level:      LevelWarn,
+		Message:    fmt.Sprintf("[logger] buffer full, %d entries dropped", dropped-l.reported),
+	}
+	l.reported = dropped
+	l.dispatch(&e)
+}
@@ -0,0 +1,143 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrUnparsableLine = errors.New("not a log line")
+
+// ParseLine parses a line written by TextEncoder or JSONEncoder, telling
+// them apart by the leading '{'. timeFormat is the time layout of the
+// logger, the default one if empty. Fields written by TextEncoder are left
+// at the end of Message.
+func ParseLine(line []byte, timeFormat string) (*Entry, error) {
+	if timeFormat == "" {
+		timeFormat = default_time_format
+	}
+
+	line = bytes.TrimRight(line, "\r\n")
+	if len(line) > 0 && line[0] == '{' {
+		return parse_json(line, timeFormat)
+	}
+	return parse_text(string(line), timeFormat)
+}
+
+// parse_text parses
+//
+//	time [LEVEL] |SYSTEM| {name} [pkg:file:func(..):line] msg
+//
+// where SYSTEM, name and caller are optional.
+func parse_text(s string, timeFormat string) (*Entry, error) {
+	e := &Entry{TimeFormat: timeFormat}
+
+	// the time may contain spaces, find the level after it
+	i := 0
+	for {
+		j := strings.Index(s[i:], " [")
+		if j < 0 {
+			return nil, ErrUnparsableLine
+		}
+		i += j
+
+		if k := strings.Index(s[i+2:], "] "); k > 0 {
+			if lv, err := ParseLevel(s[i+2 : i+2+k]); err == nil {
+				e.Level = lv
+				break
+			}
+		}
+		i += 2
+	}
+
+	t, err := time.ParseInLocation(timeFormat, s[:i], time.Local)
+	if err != nil {
+		return nil, ErrUnparsableLine
+	}
+	e.Time = t
+
+	s = s[i+2:]
+	s = s[strings.Index(s, "] ")+2:]
+
+	if strings.HasPrefix(s, "|") {
+		if k := strings.Index(s[1:], "| "); k >= 0 {
+			e.System = s[1 : 1+k]
+			s = s[k+3:]
+		}
+	}
+	if strings.HasPrefix(s, "{") {
+		if k := strings.Index(s, "} "); k > 0 {
+			e.Name = s[1:k]
+			s = s[k+2:]
+		}
+	}
+	if strings.HasPrefix(s, "[") {
+		if k := strings.Index(s, "] "); k > 0 && strings.Contains(s[:k], "(..):") {
+			e.Caller = s[1:k]
+			s = s[k+2:]
+		} else if strings.HasSuffix(s, "]") && strings.Contains(s, "(..):") {
+			e.Caller = s[1 : len(s)-1]
+			s = ""
+		}
+	}
+	e.Message = s
+	return e, nil
+}
+
+func parse_json(line []byte, timeFormat string) (*Entry, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(line, &m); err != nil {
+		return nil, ErrUnparsableLine
+	}
+
+	str := func(key string) string {
+		s, _ := m[key].(string)
+		delete(m, key)
+		return s
+	}
+
+	e := &Entry{TimeFormat: timeFormat}
+
+	ts := str("time")
+	t, err := time.ParseInLocation(timeFormat, ts, time.Local)
+	if err != nil {
+		if t, err = time.Parse(time.RFC3339Nano, ts); err != nil {
+			return nil, ErrUnparsableLine
+		}
+	}
+	e.Time = t
+
+	if e.Level, err = ParseLevel(str("level")); err != nil {
+		return nil, ErrUnparsableLine
+	}
+	e.System = str("system")
+	e.Name = str("logger")
+	e.Caller = str("caller")
+	e.Message = str("msg")
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, ok := m[k].(string)
+		if !ok {
+			v = fmt.Sprint(m[k])
+		}
+		e.Fields = append(e.Fields, Field{k, v})
+	}
+	return e, nil
+}
+
+// CallerPackage returns the package of e.Caller.
+func (e *Entry) CallerPackage() string {
+	if i := strings.IndexByte(e.Caller, ':'); i >= 0 {
+		return e.Caller[:i]
+	}
+	return ""
+}
@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLine(t *testing.T) {
+	e := &Entry{
+		Time:       time.Date(2017, 7, 28, 17, 14, 36, 928000000, time.Local),
+		TimeFormat: default_time_format,
+		Level:      LevelError,
+		System:     "DEMO",
+		Name:       "dirdiff.walk",
+		Caller:     "github.com/stormgbs/gopkg/dirdiff:walk.go:WalkDir(..):35",
+		Message:    `say [hi] {x} |y|`,
+		Fields:     []Field{{"request_id", "r1"}},
+	}
+
+	for _, enc := range []Encoder{TextEncoder{}, JSONEncoder{}} {
+		got, err := ParseLine(enc.Encode(e), "")
+		if err != nil {
+			t.Errorf("%T: %v", enc, err)
+			continue
+		}
+
+		want := *e
+		if _, ok := enc.(TextEncoder); ok {
+			want.Message += " request_id=r1"
+			want.Fields = nil
+		}
+		if !got.Time.Equal(want.Time) || got.Level != want.Level || got.System != want.System ||
+			got.Name != want.Name || got.Caller != want.Caller || got.Message != want.Message ||
+			len(got.Fields) != len(want.Fields) {
+			t.Errorf("%T:\n got %+v\nwant %+v", enc, got, want)
+		}
+	}
+
+	if got, _ := ParseLine([]byte("2017/07/28 17:14:36.928 [INFO] plain"), ""); got == nil || got.Message != "plain" || got.Caller != "" {
+		t.Errorf("minimal line: %+v", got)
+	}
+	if got, _ := ParseLine([]byte("2017/07/28 17:14:36.928 [INFO] || plain"), ""); got == nil || got.Message != "plain" || got.System != "" {
+		t.Errorf("empty system: %+v", got)
+	}
+	if e.CallerPackage() != "github.com/stormgbs/gopkg/dirdiff" {
+		t.Errorf("CallerPackage: %s", e.CallerPackage())
+	}
+
+	for _, bad := range []string{"", "goroutine 1 [running]:", "2017/07/28 [NOPE] x", `{"msg":"x"}`} {
+		if _, err := ParseLine([]byte(bad), ""); err != ErrUnparsableLine {
+			t.Errorf("%q: got %v", bad, err)
+		}
+	}
+}
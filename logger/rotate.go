@@ -0,0 +1,345 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type Rollover uint8
+
+const (
+	RolloverNone Rollover = iota
+	RolloverDaily
+	RolloverHourly
+)
+
+const rotate_time_format = "20060102-150405"
+
+var ErrRotateWriterClosed = errors.New("rotate writer closed")
+
+type RotateConfig struct {
+	// MaxSize rotates the file once it would grow past this many bytes, 0 disables it.
+	MaxSize int64
+	// Rollover rotates the file at the start of every day or hour.
+	Rollover Rollover
+	// MaxBackups is how many rotated files to keep, 0 keeps all of them.
+	MaxBackups int
+	// Compress gzips rotated files in the background.
+	Compress bool
+}
+
+// RotateWriter is an io.WriteCloser writing to a file which is rotated by
+// size and/or time. It can be handed to NewLogger, NewSimpleLogger or SetWriter.
+type RotateWriter struct {
+	mutex sync.Mutex
+
+	filename string
+	cfg      RotateConfig
+
+	fp            *os.File
+	size          int64
+	next_rollover time.Time
+	closed        bool
+
+	post_mutex sync.Mutex
+	post_wg    sync.WaitGroup
+	chsig      chan os.Signal
+}
+
+func NewRotateWriter(filename string, cfg *RotateConfig) (*RotateWriter, error) {
+	w := &RotateWriter{
+		filename: filename,
+	}
+	if cfg != nil {
+		w.cfg = *cfg
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, err
+	}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return 0, ErrRotateWriterClosed
+	}
+
+	now := time.Now()
+	if !w.next_rollover.IsZero() && !now.Before(w.next_rollover) {
+		if err := w.rotate(w.next_rollover.Add(-w.period())); err != nil {
+			return 0, err
+		}
+	} else if w.cfg.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.cfg.MaxSize {
+		if err := w.rotate(now); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.fp.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate moves the current file aside and starts a new one.
+func (w *RotateWriter) Rotate() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return ErrRotateWriterClosed
+	}
+	return w.rotate(time.Now())
+}
+
+// Reopen closes and reopens the file by name, for use after an external
+// tool such as logrotate has moved it.
+func (w *RotateWriter) Reopen() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return ErrRotateWriterClosed
+	}
+
+	w.fp.Close()
+	return w.open()
+}
+
+// ReopenOnSignal calls Reopen whenever one of sigs is received, SIGHUP by default.
+func (w *RotateWriter) ReopenOnSignal(sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed || w.chsig != nil {
+		return
+	}
+
+	w.chsig = make(chan os.Signal, 1)
+	signal.Notify(w.chsig, sigs...)
+
+	go func(ch chan os.Signal) {
+		for range ch {
+			if err := w.Reopen(); err != nil {
+				fmt.Fprintf(os.Stderr, "[logger] reopen %s error: %v\n", w.filename, err)
+			}
+		}
+	}(w.chsig)
+}
+
+// Close closes the file and waits for pending compression of rotated files.
+func (w *RotateWriter) Close() error {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.closed = true
+
+	if w.chsig != nil {
+		signal.Stop(w.chsig)
+		close(w.chsig)
+		w.chsig = nil
+	}
+
+	err := w.fp.Close()
+	w.mutex.Unlock()
+
+	w.post_wg.Wait()
+	return err
+}
+
+func (w *RotateWriter) open() error {
+	fp, err := os.OpenFile(w.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0664)
+	if err != nil {
+		return err
+	}
+
+	finfo, err := fp.Stat()
+	if err != nil {
+		fp.Close()
+		return err
+	}
+
+	w.fp = fp
+	w.size = finfo.Size()
+
+	if w.cfg.Rollover != RolloverNone {
+		w.next_rollover = w.period_start(time.Now()).Add(w.period())
+	}
+	return nil
+}
+
+func (w *RotateWriter) period() time.Duration {
+	switch w.cfg.Rollover {
+	case RolloverHourly:
+		return time.Hour
+	case RolloverDaily:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+func (w *RotateWriter) period_start(t time.Time) time.Time {
+	switch w.cfg.Rollover {
+	case RolloverHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case RolloverDaily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	return t
+}
+
+func (w *RotateWriter) rotate(t time.Time) error {
+	if err := w.fp.Close(); err != nil {
+		return err
+	}
+
+	backup := w.filename + "." + t.Format(rotate_time_format)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(backup); os.IsNotExist(err) {
+			if _, err := os.Lstat(backup + ".gz"); os.IsNotExist(err) {
+				break
+			}
+		}
+		backup = fmt.Sprintf("%s.%s.%d", w.filename, t.Format(rotate_time_format), i)
+	}
+
+	if err := os.Rename(w.filename, backup); err != nil && !os.IsNotExist(err) {
+		w.open()
+		return err
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	if w.cfg.Compress || w.cfg.MaxBackups > 0 {
+		w.post_wg.Add(1)
+		go w.post_rotate(backup)
+	}
+	return nil
+}
+
+func (w *RotateWriter) post_rotate(backup string) {
+	defer w.post_wg.Done()
+
+	w.post_mutex.Lock()
+	defer w.post_mutex.Unlock()
+
+	if w.cfg.Compress {
+		if err := gzip_file(backup); err != nil {
+			fmt.Fprintf(os.Stderr, "[logger] gzip %s error: %v\n", backup, err)
+		}
+	}
+
+	if w.cfg.MaxBackups > 0 {
+		backups, err := w.backups()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[logger] list backups of %s error: %v\n", w.filename, err)
+			return
+		}
+
+		for i := 0; i < len(backups)-w.cfg.MaxBackups; i++ {
+			os.Remove(backups[i])
+		}
+	}
+}
+
+// backups returns rotated files of w, oldest first.
+func (w *RotateWriter) backups() ([]string, error) {
+	return Backups(w.filename)
+}
+
+// Backups returns the files rotated from filename by a RotateWriter, oldest
+// first, compressed or not.
+func Backups(filename string) ([]string, error) {
+	files, err := filepath.Glob(filename + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	type backup struct {
+		name  string
+		mtime time.Time
+	}
+
+	var bs []backup
+	for _, f := range files {
+		suffix := strings.TrimSuffix(f[len(filename)+1:], ".gz")
+		if len(suffix) < len(rotate_time_format) {
+			continue
+		}
+		if _, err := time.Parse(rotate_time_format, suffix[:len(rotate_time_format)]); err != nil {
+			continue
+		}
+
+		finfo, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		bs = append(bs, backup{f, finfo.ModTime()})
+	}
+
+	sort.Slice(bs, func(i, j int) bool {
+		if bs[i].mtime.Equal(bs[j].mtime) {
+			return bs[i].name < bs[j].name
+		}
+		return bs[i].mtime.Before(bs[j].mtime)
+	})
+
+	names := make([]string, len(bs))
+	for i := range bs {
+		names[i] = bs[i].name
+	}
+	return names, nil
+}
+
+func gzip_file(file string) error {
+	src, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(file+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0664)
+	if err != nil {
+		return err
+	}
+
+	gzw := gzip.NewWriter(dst)
+	if _, err = io.Copy(gzw, src); err == nil {
+		err = gzw.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.Remove(file + ".gz")
+		return err
+	}
+
+	return os.Remove(file)
+}
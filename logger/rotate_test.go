@@ -0,0 +1,107 @@
+package logger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotateWriterMaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "app.log")
+	w, err := NewRotateWriter(file, &RotateConfig{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := []byte("0123456789\n")
+	for i := 0; i < 5; i++ {
+		if _, err := w.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	backups, err := w.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("got %d backups %v, want 2", len(backups), backups)
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(line) {
+		t.Errorf("current file %q, want %q", data, line)
+	}
+}
+
+func TestRotateWriterRolloverCompress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "app.log")
+	w, err := NewRotateWriter(file, &RotateConfig{Rollover: RolloverHourly, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("old\n"))
+
+	w.mutex.Lock()
+	w.next_rollover = time.Now().Add(-time.Second)
+	w.mutex.Unlock()
+
+	w.Write([]byte("new\n"))
+	w.Close()
+
+	backups, err := w.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], ".gz") {
+		t.Fatalf("got backups %v, want one gzipped file", backups)
+	}
+}
+
+func TestRotateWriterReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "app.log")
+	w, err := NewRotateWriter(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	w.Write([]byte("before\n"))
+	if err := os.Rename(file, file+".moved"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("after\n"))
+
+	data, _ := ioutil.ReadFile(file)
+	if string(data) != "after\n" {
+		t.Errorf("got %q after reopen", data)
+	}
+}
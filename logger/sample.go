@@ -0,0 +1,191 @@
+package logger
+
+import (
+	"sync"
+	"time"
+)
+
+// SampleKey decides which entries count as repeats of each other.
+type SampleKey uint8
+
+const (
+	// SampleByMessage groups entries by logger name, level and format string,
+	// so that "Get(%s) error: %v" is limited whatever its arguments.
+	SampleByMessage SampleKey = iota
+	// SampleByCaller groups entries by the line of code which logged them.
+	SampleByCaller
+)
+
+const default_sample_interval = time.Second
+
+type SampleConfig struct {
+	// First entries of a group are written in every Interval, at least 1.
+	First int
+	// Thereafter one of every Thereafter entries past First is written,
+	// 0 drops them all until the next Interval.
+	Thereafter int
+	// Interval is the rate limit window, 1s by default.
+	Interval time.Duration
+	By       SampleKey
+}
+
+type sample_key struct {
+	name   string
+	level  Level
+	format string
+	pc     uintptr
+}
+
+type sample_counter struct {
+	start      time.Time
+	n          int
+	suppressed int
+	pc         uintptr // of the last entry, for the caller of the report
+}
+
+// sample_report is the count of entries of a group dropped in a window.
+type sample_report struct {
+	key        sample_key
+	pc         uintptr
+	suppressed int
+}
+
+// sampler limits repeated entries. The entries dropped in a window of a
+// group are reported by a "suppressed N similar messages" entry, written
+// ahead of the first entry of the next window, or once every Interval for
+// all the groups whose window ended, and when the logger is closed.
+type sampler struct {
+	mutex      sync.Mutex
+	cfg        SampleConfig
+	counters   map[sample_key]*sample_counter
+	next_sweep time.Time
+}
+
+func new_sampler(cfg *SampleConfig) *sampler {
+	s := &sampler{
+		cfg:      *cfg,
+		counters: make(map[sample_key]*sample_counter),
+	}
+	if s.cfg.First < 1 {
+		s.cfg.First = 1
+	}
+	if s.cfg.Interval <= 0 {
+		s.cfg.Interval = default_sample_interval
+	}
+	return s
+}
+
+// check reports whether the entry of key, logged at pc, is written at now,
+// along with the dropped entries to report first.
+func (s *sampler) check(key sample_key, pc uintptr, now time.Time) (bool, []sample_report) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var reports []sample_report
+
+	c, ok := s.counters[key]
+	if !ok {
+		c = &sample_counter{start: now}
+		s.counters[key] = c
+	}
+
+	if now.Sub(c.start) >= s.cfg.Interval {
+		if c.suppressed > 0 {
+			reports = append(reports, sample_report{key, c.pc, c.suppressed})
+		}
+		c.start, c.n, c.suppressed = now, 0, 0
+	}
+	c.pc = pc
+
+	if !now.Before(s.next_sweep) {
+		reports = s.sweep(reports, now, false)
+	}
+
+	c.n++
+	if c.n <= s.cfg.First || (s.cfg.Thereafter > 0 && (c.n-s.cfg.First)%s.cfg.Thereafter == 0) {
+		return true, reports
+	}
+	c.suppressed++
+	return false, reports
+}
+
+// flush returns the dropped entries of every group, for the logger being closed.
+func (s *sampler) flush(now time.Time) []sample_report {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.sweep(nil, now, true)
+}
+
+// sweep appends to reports the dropped entries of the groups whose window
+// ended, of all groups if all, and forgets the groups idle for a while.
+func (s *sampler) sweep(reports []sample_report, now time.Time, all bool) []sample_report {
+	s.next_sweep = now.Add(s.cfg.Interval)
+
+	for k, c := range s.counters {
+		ended := now.Sub(c.start) >= s.cfg.Interval
+		if c.suppressed > 0 && (ended || all) {
+			reports = append(reports, sample_report{k, c.pc, c.suppressed})
+			c.suppressed = 0
+		}
+
+		if len(s.counters) >= 1024 && now.Sub(c.start) >= 10*s.cfg.Interval {
+			delete(s.counters, k)
+		}
+	}
+	return reports
+}
+
+// SetSampling limits repeated entries as described by cfg, nil turns it off.
+// The entries dropped by the previous settings are reported.
+func (c *core) SetSampling(cfg *SampleConfig) {
+	var s *sampler
+	if cfg != nil {
+		s = new_sampler(cfg)
+	}
+	old, _ := c.sampler.Swap(s).(*sampler)
+
+	for _, e := range c.sample_flush(old) {
+		c.output(e)
+	}
+}
+
+// sampling returns the current sampler, nil if sampling is off.
+func (c *core) sampling() *sampler {
+	s, _ := c.sampler.Load().(*sampler)
+	return s
+}
+
+// sample is called by log_pc, it returns false if the entry is dropped.
+func (c *core) sample(s *sampler, pc uintptr, name string, level Level, format string) bool {
+	key := sample_key{name: name, level: level}
+	if s.cfg.By == SampleByCaller {
+		key.pc = pc
+	} else {
+		key.format = format
+	}
+
+	ok, reports := s.check(key, pc, time.Now())
+	for _, r := range reports {
+		c.output(c.sample_entry(r))
+	}
+	return ok
+}
+
+// sample_flush returns the entries reporting what s dropped and has not
+// reported yet, s may be nil.
+func (c *core) sample_flush(s *sampler) []*Entry {
+	if s == nil {
+		return nil
+	}
+
+	var es []*Entry
+	for _, r := range s.flush(time.Now()) {
+		es = append(es, c.sample_entry(r))
+	}
+	return es
+}
+
+func (c *core) sample_entry(r sample_report) *Entry {
+	return c.new_entry(r.pc, r.key.name, r.key.level, "[logger] suppressed %d similar messages", r.suppressed)
+}
@@ -0,0 +1,148 @@
+package logger
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSamplerCheck(t *testing.T) {
+	s := new_sampler(&SampleConfig{First: 2, Thereafter: 3, Interval: time.Second})
+	key := sample_key{format: "x"}
+	now := time.Now()
+
+	var written []int
+	for i := 1; i <= 10; i++ {
+		if ok, reports := s.check(key, 0, now); ok {
+			written = append(written, i)
+		} else if len(reports) != 0 {
+			t.Fatalf("reports %v inside the window", reports)
+		}
+	}
+	if len(written) != 4 || written[0] != 1 || written[1] != 2 || written[2] != 5 || written[3] != 8 {
+		t.Errorf("written %v, want [1 2 5 8]", written)
+	}
+
+	ok, reports := s.check(key, 0, now.Add(time.Second))
+	if !ok || len(reports) != 1 || reports[0].suppressed != 6 {
+		t.Errorf("new window: ok %v, reports %v, want true, 6 suppressed", ok, reports)
+	}
+}
+
+func TestSamplerSweep(t *testing.T) {
+	s := new_sampler(&SampleConfig{First: 1, Interval: time.Second})
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		s.check(sample_key{format: "burst"}, 0, now)
+	}
+
+	// another group logging after the window reports the silent one
+	ok, reports := s.check(sample_key{format: "other"}, 0, now.Add(time.Second))
+	if !ok || len(reports) != 1 || reports[0].key.format != "burst" || reports[0].suppressed != 4 {
+		t.Errorf("ok %v, reports %v, want burst with 4 suppressed", ok, reports)
+	}
+
+	for i := 0; i < 3; i++ {
+		s.check(sample_key{format: "burst"}, 0, now.Add(1500*time.Millisecond))
+	}
+	if reports := s.flush(now.Add(1500 * time.Millisecond)); len(reports) != 1 || reports[0].suppressed != 2 {
+		t.Errorf("flush reports %v, want burst with 2 suppressed", reports)
+	}
+	if reports := s.flush(now.Add(1500 * time.Millisecond)); len(reports) != 0 {
+		t.Errorf("reported twice: %v", reports)
+	}
+}
+
+func TestSamplingReportedOnClose(t *testing.T) {
+	for name, impl := range map[string]func(w *buffer_closer) Interface{
+		"Logger":       func(w *buffer_closer) Interface { return NewLogger(w) },
+		"SimpleLogger": func(w *buffer_closer) Interface { return NewSimpleLogger(w) },
+	} {
+		w := &buffer_closer{}
+		l := impl(w)
+		l.SetSampling(&SampleConfig{First: 1, Interval: time.Hour})
+
+		for i := 0; i < 10; i++ {
+			l.Warn("burst")
+		}
+		l.(interface{ Close() }).Close()
+
+		if out := w.buf.String(); !strings.Contains(out, "[logger] suppressed 9 similar messages") {
+			t.Errorf("%s: summary missing:\n%s", name, out)
+		}
+	}
+}
+
+func TestSampling(t *testing.T) {
+	w := &buffer_closer{}
+	l := NewSimpleLogger(w)
+	l.EnableCallerInfo()
+	l.SetSampling(&SampleConfig{First: 3, Interval: time.Hour})
+
+	for i := 0; i < 100; i++ {
+		l.Error("Get(%d) error", i)
+	}
+	for i := 0; i < 2; i++ {
+		l.Error("other")
+	}
+
+	out := w.buf.String()
+	if n := strings.Count(out, "Get("); n != 3 {
+		t.Errorf("got %d Get lines, want 3:\n%s", n, out)
+	}
+	if n := strings.Count(out, "other"); n != 2 {
+		t.Errorf("got %d other lines, want 2:\n%s", n, out)
+	}
+
+	// pretend the window is over
+	for _, c := range l.sampling().counters {
+		c.start = c.start.Add(-2 * time.Hour)
+	}
+	w.buf.Reset()
+	l.Error("Get(%d) error", 100)
+
+	lines := strings.Split(strings.TrimSpace(w.buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "] [logger] suppressed 97 similar messages") ||
+		!strings.Contains(lines[0], "sample_test.go:TestSampling") {
+		t.Errorf("unexpected summary:\n%s", w.buf.Bytes())
+	}
+}
+
+func TestSamplingByCaller(t *testing.T) {
+	w := &buffer_closer{}
+	l := NewSimpleLogger(w)
+	l.SetSampling(&SampleConfig{First: 1, By: SampleByCaller})
+
+	for i := 0; i < 5; i++ {
+		l.Info("a")
+		l.Info("a")
+	}
+	if n := strings.Count(w.buf.String(), "a\n"); n != 2 {
+		t.Errorf("got %d lines, want one per call site:\n%s", n, w.buf.Bytes())
+	}
+}
+
+func TestSetSamplingConcurrent(t *testing.T) {
+	l := NewSimpleLogger(&buffer_closer{})
+	defer l.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				l.Info("info")
+				l.Named("child").Info("info")
+			}
+		}()
+	}
+
+	for i := 0; i < 1000; i++ {
+		l.SetSampling(&SampleConfig{First: 1})
+		l.SetSampling(nil)
+	}
+	wg.Wait()
+}
@@ -1,55 +1,32 @@
 package logger
 
 import (
-	"fmt"
 	"io"
 	"os"
-	"sync"
-	"time"
 )
 
+// SimpleLogger writes every entry to its writer synchronously.
 type SimpleLogger struct {
-	mutex sync.Mutex
+	core
 
-	disable            bool
-	w                  io.WriteCloser
-	time_format        string
-	level              Level
-	enable_caller_info bool
-	caller_path_number int
-
-	System string
+	w   io.WriteCloser
+	enc Encoder // as set by SetEncoder, core.encoder is it for w
 }
 
 func NewDefaultSimpleLogger() *SimpleLogger {
-	l := &SimpleLogger{
-		w:                  os.Stdout,
-		time_format:        default_time_format,
-		level:              LevelDebug,
-		enable_caller_info: true,
-		caller_path_number: 3,
-	}
+	l := NewSimpleLogger(os.Stdout)
+	l.enable_caller_info = true
 	return l
 }
 
 func NewSimpleLogger(wc io.WriteCloser) *SimpleLogger {
 	l := &SimpleLogger{
-		w:                  wc,
-		time_format:        default_time_format,
-		level:              LevelDebug,
-		caller_path_number: 3,
+		w: wc,
 	}
+	l.core.init(l.output)
 	return l
 }
 
-func (l *SimpleLogger) Enable() {
-	l.disable = false
-}
-
-func (l *SimpleLogger) Disable() {
-	l.disable = true
-}
-
 func (l *SimpleLogger) SetWriter(w io.WriteCloser) {
 	if w == nil {
 		panic("SetWriter w is null")
@@ -58,6 +35,9 @@ func (l *SimpleLogger) SetWriter(w io.WriteCloser) {
 	l.mutex.Lock()
 	old_w := l.w
 	l.w = w
+	if l.enc != nil {
+		l.encoder = resolve_encoder(l.enc, w)
+	}
 	l.mutex.Unlock()
 
 	if old_w != nil && old_w != os.Stdout && old_w != os.Stderr {
@@ -66,127 +46,34 @@ func (l *SimpleLogger) SetWriter(w io.WriteCloser) {
 	}
 }
 
-func (l *SimpleLogger) SetLevel(lv Level) {
-	l.level = lv
-}
+func (l *SimpleLogger) SetEncoder(enc Encoder) {
+	if enc == nil {
+		panic("SetEncoder enc is null")
+	}
 
-func (l *SimpleLogger) SetTimeFormat(format string) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
-	l.time_format = format
-}
-
-func (l *SimpleLogger) EnableCallerInfo() {
-	l.enable_caller_info = true
+	l.enc = enc
+	l.encoder = resolve_encoder(enc, l.w)
 }
 
-func (l *SimpleLogger) DisableCallerInfo() {
-	l.enable_caller_info = false
-}
-
-func (l *SimpleLogger) write(level_str string, format string, a ...interface{}) {
-	var s string
-
-	if !l.enable_caller_info {
-		if l.System != "" {
-			s = fmt.Sprintf(time.Now().Format(l.time_format)+" ["+level_str+"] |"+l.System+"| "+format+"\n", a...)
-		} else {
-			s = fmt.Sprintf(time.Now().Format(l.time_format)+" ["+level_str+"] |"+l.System+"| "+format+"\n", a...)
-		}
-	} else {
-		if l.System != "" {
-			s = fmt.Sprintf(time.Now().Format(l.time_format)+" ["+level_str+"] |"+l.System+"| ["+get_caller_info(l.caller_path_number).String()+"] "+format+"\n", a...)
-		} else {
-			s = fmt.Sprintf(time.Now().Format(l.time_format)+" ["+level_str+"] |"+l.System+"| ["+get_caller_info(l.caller_path_number).String()+"] "+format+"\n", a...)
-		}
-
-	}
-
-	l.w.Write(StringToReadonlySlice(&s))
-}
-
-func (l *SimpleLogger) Debug4(format string, a ...interface{}) {
-	if l.disable {
-		return
-	}
-	if l.level <= LevelDebug4 {
-		l.write("DEBUG4", format, a...)
-	}
-}
-
-func (l *SimpleLogger) Debug3(format string, a ...interface{}) {
-	if l.disable {
-		return
-	}
-	if l.level <= LevelDebug3 {
-		l.write("DEBUG3", format, a...)
-	}
-}
+func (l *SimpleLogger) output(e *Entry) {
+	b := get_buffer()
+	*b = append_entry(l.encoder, *b, e)
 
-func (l *SimpleLogger) Debug2(format string, a ...interface{}) {
-	if l.disable {
-		return
-	}
-	if l.level <= LevelDebug2 {
-		l.write("DEBUG2", format, a...)
-	}
-}
-
-func (l *SimpleLogger) Debug1(format string, a ...interface{}) {
-	if l.disable {
-		return
-	}
-	if l.level <= LevelDebug1 {
-		l.write("DEBUG1", format, a...)
-	}
-}
-
-func (l *SimpleLogger) Debug(format string, a ...interface{}) {
-	if l.disable {
-		return
-	}
-	if l.level <= LevelDebug {
-		l.write("DEBUG", format, a...)
-	}
-}
-
-func (l *SimpleLogger) Info(format string, a ...interface{}) {
-	if l.disable {
-		return
-	}
-	if l.level <= LevelInfo {
-		l.write("INFO", format, a...)
-	}
-}
+	l.mutex.Lock()
+	l.w.Write(*b)
+	l.mutex.Unlock()
 
-func (l *SimpleLogger) Warn(format string, a ...interface{}) {
-	if l.disable {
-		return
-	}
-	if l.level <= LevelWarn {
-		l.write("WARN", format, a...)
-	}
+	put_buffer(b)
 }
 
-func (l *SimpleLogger) Error(format string, a ...interface{}) {
-	if l.disable {
-		return
-	}
-	if l.level <= LevelError {
-		l.write("ERROR", format, a...)
-	}
-}
-
-func (l *SimpleLogger) Critical(format string, a ...interface{}) {
-	if l.disable {
-		return
-	}
-	if l.level <= LevelCrit {
-		l.write("CRITICAL", format, a...)
+func (l *SimpleLogger) Close() {
+	for _, e := range l.sample_flush(l.sampling()) {
+		l.output(e)
 	}
-}
+	l.close_hooks()
 
-func (l *SimpleLogger) Close() {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
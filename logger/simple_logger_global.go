@@ -7,16 +7,17 @@ import (
 
 var simpleLg = NewDefaultSimpleLogger()
 
-func init() {
-	simpleLg.caller_path_number = 4
+// Default returns the logger behind the package level functions.
+func Default() *SimpleLogger {
+	return simpleLg
 }
 
 func Enable() {
-	simpleLg.disable = false
+	simpleLg.Enable()
 }
 
 func Disable() {
-	simpleLg.disable = true
+	simpleLg.Disable()
 }
 
 func SetLogFile(file string) error {
@@ -28,132 +29,108 @@ func SetLogFile(file string) error {
 	return nil
 }
 
-func SetWriter(w io.WriteCloser) {
-	if w == nil {
-		panic("SetWriter w is null")
+// SetRotateLogFile is like SetLogFile, the file is rotated according to cfg
+// and reopened on SIGHUP.
+func SetRotateLogFile(file string, cfg *RotateConfig) error {
+	w, err := NewRotateWriter(file, cfg)
+	if err != nil {
+		return err
 	}
+	w.ReopenOnSignal()
+	SetWriter(w)
+	return nil
+}
 
-	simpleLg.mutex.Lock()
-	old_w := simpleLg.w
-	simpleLg.w = w
-	simpleLg.mutex.Unlock()
-
-	if old_w != nil && old_w != os.Stdout && old_w != os.Stderr {
-		old_w.Close()
-		old_w = nil
-	}
+func SetWriter(w io.WriteCloser) {
+	simpleLg.SetWriter(w)
 }
 
 func SetLevel(lv Level) {
-	simpleLg.level = lv
+	simpleLg.SetLevel(lv)
+}
+
+// Levels returns the level overrides of the child loggers created by Named.
+func Levels() *LevelTree {
+	return simpleLg.Levels()
+}
+
+// Named returns a child of the global logger.
+func Named(name string) *NamedLogger {
+	return simpleLg.Named(name)
 }
 
 func SetTimeFormat(format string) {
-	simpleLg.mutex.Lock()
-	defer simpleLg.mutex.Unlock()
-	simpleLg.time_format = format
+	simpleLg.SetTimeFormat(format)
+}
+
+func SetEncoder(enc Encoder) {
+	simpleLg.SetEncoder(enc)
 }
 
 func EnableCallerInfo() {
-	simpleLg.enable_caller_info = true
+	simpleLg.EnableCallerInfo()
 }
 
 func DisableCallerInfo() {
-	simpleLg.enable_caller_info = false
+	simpleLg.DisableCallerInfo()
 }
 
-func Debug4(format string, a ...interface{}) {
-	if simpleLg.disable {
-		return
-	}
+// SetSampling limits repeated entries of the global logger, nil turns it off.
+func SetSampling(cfg *SampleConfig) {
+	simpleLg.SetSampling(cfg)
+}
 
-	if simpleLg.level <= LevelDebug4 {
-		simpleLg.write("DEBUG4", format, a...)
-	}
+// AddHook calls fn with the entries of the global logger selected by cfg.
+func AddHook(fn func(e *Entry), cfg *HookConfig) *Hook {
+	return simpleLg.AddHook(fn, cfg)
 }
 
-func Debug3(format string, a ...interface{}) {
-	if simpleLg.disable {
-		return
-	}
+func SetCallerFormat(f CallerFormat) {
+	simpleLg.SetCallerFormat(f)
+}
 
-	if simpleLg.level <= LevelDebug3 {
-		simpleLg.write("DEBUG3", format, a...)
-	}
+// Output writes an entry with the global logger, reporting the caller skip
+// frames above the code calling Output.
+func Output(skip int, level Level, format string, a ...interface{}) {
+	simpleLg.log(simpleLg.caller_path_number+skip, "", nil, level, format, a...)
 }
 
-func Debug2(format string, a ...interface{}) {
-	if simpleLg.disable {
-		return
-	}
+func Debug4(format string, a ...interface{}) {
+	simpleLg.log(simpleLg.caller_path_number, "", nil, LevelDebug4, format, a...)
+}
 
-	if simpleLg.level <= LevelDebug2 {
-		simpleLg.write("DEBUG2", format, a...)
-	}
+func Debug3(format string, a ...interface{}) {
+	simpleLg.log(simpleLg.caller_path_number, "", nil, LevelDebug3, format, a...)
 }
 
-func Debug1(format string, a ...interface{}) {
-	if simpleLg.disable {
-		return
-	}
+func Debug2(format string, a ...interface{}) {
+	simpleLg.log(simpleLg.caller_path_number, "", nil, LevelDebug2, format, a...)
+}
 
-	if simpleLg.level <= LevelDebug1 {
-		simpleLg.write("DEBUG1", format, a...)
-	}
+func Debug1(format string, a ...interface{}) {
+	simpleLg.log(simpleLg.caller_path_number, "", nil, LevelDebug1, format, a...)
 }
 
 func Debug(format string, a ...interface{}) {
-	if simpleLg.disable {
-		return
-	}
-
-	if simpleLg.level <= LevelDebug {
-		simpleLg.write("DEBUG", format, a...)
-	}
+	simpleLg.log(simpleLg.caller_path_number, "", nil, LevelDebug, format, a...)
 }
 
 func Info(format string, a ...interface{}) {
-	if simpleLg.disable {
-		return
-	}
-	if simpleLg.level <= LevelInfo {
-		simpleLg.write("INFO", format, a...)
-	}
+	simpleLg.log(simpleLg.caller_path_number, "", nil, LevelInfo, format, a...)
 }
 
 func Warn(format string, a ...interface{}) {
-	if simpleLg.disable {
-		return
-	}
-	if simpleLg.level <= LevelWarn {
-		simpleLg.write("WARN", format, a...)
-	}
+	simpleLg.log(simpleLg.caller_path_number, "", nil, LevelWarn, format, a...)
 }
 
 func Error(format string, a ...interface{}) {
-	if simpleLg.disable {
-		return
-	}
-	if simpleLg.level <= LevelError {
-		simpleLg.write("ERROR", format, a...)
-	}
+	simpleLg.log(simpleLg.caller_path_number, "", nil, LevelError, format, a...)
 }
 
 func Critical(format string, a ...interface{}) {
-	if simpleLg.disable {
-		return
-	}
-	if simpleLg.level <= LevelCrit {
-		simpleLg.write("CRITICAL", format, a...)
-	}
+	simpleLg.log(simpleLg.caller_path_number, "", nil, LevelCrit, format, a...)
 }
 
 func Close() {
-	simpleLg.mutex.Lock()
-	defer simpleLg.mutex.Unlock()
-
-	w := simpleLg.w
-	if w != nil && w != os.Stdout && w != os.Stderr {
-		w.Close()
-	}
+	simpleLg.Close()
 }
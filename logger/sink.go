@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"io"
+	"os"
+)
+
+// Sink is a destination of log entries. A Logger calls WriteEntry for every
+// entry passing the sink's level, then Flush at the end of each batch.
+type Sink interface {
+	WriteEntry(e *Entry) error
+	Flush() error
+	Close() error
+}
+
+type SinkConfig struct {
+	Sink  Sink
+	Level Level
+}
+
+type sink_entry struct {
+	sink  Sink
+	level Level
+}
+
+// WriterSink encodes entries into an io.WriteCloser, buffering them so that
+// a batch reaches the writer in as few Write calls as possible.
+type WriterSink struct {
+	w           io.WriteCloser
+	encoder     Encoder
+	buf         []byte
+	batch_bytes int
+}
+
+func NewWriterSink(w io.WriteCloser, enc Encoder) *WriterSink {
+	if enc == nil {
+		enc = default_encoder
+	}
+	return &WriterSink{
+		w:           w,
+		encoder:     resolve_encoder(enc, w),
+		batch_bytes: default_batch_bytes,
+	}
+}
+
+func (s *WriterSink) WriteEntry(e *Entry) error {
+	s.buf = append_entry(s.encoder, s.buf, e)
+	if len(s.buf) >= s.batch_bytes {
+		return s.write()
+	}
+	return nil
+}
+
+func (s *WriterSink) Flush() error {
+	if err := s.write(); err != nil {
+		return err
+	}
+
+	if f, ok := s.w.(interface {
+		Flush() error
+	}); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close flushes and closes the writer, os.Stdout and os.Stderr are left open.
+func (s *WriterSink) Close() error {
+	err := s.Flush()
+	if s.w != os.Stdout && s.w != os.Stderr {
+		if cerr := s.w.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (s *WriterSink) write() error {
+	if len(s.buf) == 0 {
+		return nil
+	}
+
+	_, err := s.w.Write(s.buf)
+
+	if cap(s.buf) > 2*s.batch_bytes {
+		s.buf = nil
+	} else {
+		s.buf = s.buf[:0]
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+//go:build go1.21
+
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogHandler is a slog.Handler writing records through a logger, with the
+// attributes as fields and slog levels mapped onto logger levels.
+type SlogHandler struct {
+	n      *NamedLogger
+	fields []Field
+	group  string
+}
+
+// NewSlogHandler returns a handler logging through n, for slog.New. The
+// context of a record adds the registered context keys as FromContext does.
+func NewSlogHandler(n *NamedLogger) *SlogHandler {
+	return &SlogHandler{n: n}
+}
+
+// SlogLevel maps a slog level to a logger level: DEBUG-1 to DEBUG-4 to
+// DEBUG1 to DEBUG4, and ERROR+4 and above to CRITICAL.
+func SlogLevel(lv slog.Level) Level {
+	switch {
+	case lv <= slog.LevelDebug-4:
+		return LevelDebug4
+	case lv < slog.LevelDebug:
+		return LevelDebug - Level(slog.LevelDebug-lv)
+	case lv < slog.LevelInfo:
+		return LevelDebug
+	case lv < slog.LevelWarn:
+		return LevelInfo
+	case lv < slog.LevelError:
+		return LevelWarn
+	case lv < slog.LevelError+4:
+		return LevelError
+	}
+	return LevelCrit
+}
+
+func (h *SlogHandler) Enabled(ctx context.Context, lv slog.Level) bool {
+	return h.n.c.enabled(h.n.name, SlogLevel(lv))
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	fields := make([]Field, 0, len(h.n.fields)+len(h.fields)+r.NumAttrs())
+	fields = append(fields, h.n.fields...)
+	if ctx != nil {
+		fields = append_context_fields(fields, ctx)
+	}
+	fields = append(fields, h.fields...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = append_attr(fields, h.group, a)
+		return true
+	})
+
+	h.n.c.log_pc(h.n.c.sampling(), r.PC, h.n.name, fields, SlogLevel(r.Level), "%s", r.Message)
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.fields = make([]Field, len(h.fields), len(h.fields)+len(attrs))
+	copy(h2.fields, h.fields)
+	for _, a := range attrs {
+		h2.fields = append_attr(h2.fields, h.group, a)
+	}
+	return &h2
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.group = h.group + name + "."
+	return &h2
+}
+
+// append_attr appends a as fields named group+key, groups flattened into
+// dotted keys.
+func append_attr(fields []Field, group string, a slog.Attr) []Field {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		prefix := group
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			fields = append_attr(fields, prefix, ga)
+		}
+		return fields
+	}
+	if a.Key == "" {
+		return fields
+	}
+	return append(fields, Field{group + a.Key, v.String()})
+}
@@ -0,0 +1,61 @@
+//go:build go1.21
+
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	w := &buffer_closer{}
+	l := NewSimpleLogger(w)
+	l.EnableCallerInfo()
+	l.SetLevel(LevelDebug2)
+
+	sl := slog.New(NewSlogHandler(l.Named("slog"))).With("svc", "api").WithGroup("req")
+	ctx := NewRequestIDContext(context.Background(), "r1")
+
+	sl.InfoContext(ctx, "hello", "id", 7, slog.Group("user", "name", "alice"))
+	sl.Log(ctx, slog.LevelDebug-2, "debug2")
+	sl.Log(ctx, slog.LevelDebug-3, "filtered")
+	sl.Log(ctx, slog.LevelError+4, "crit")
+
+	lines := strings.Split(strings.TrimSpace(w.buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines:\n%s", len(lines), w.buf.Bytes())
+	}
+	if !strings.Contains(lines[0], "[INFO] || {slog} [") ||
+		!strings.HasSuffix(lines[0], "] hello request_id=r1 svc=api req.id=7 req.user.name=alice") {
+		t.Errorf("line 1: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "[DEBUG2]") || !strings.Contains(lines[2], "[CRITICAL]") {
+		t.Errorf("levels:\n%s", w.buf.Bytes())
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "slog_test.go:TestSlogHandler(..):") {
+			t.Errorf("caller is not the test: %s", line)
+		}
+	}
+}
+
+func TestSlogLevel(t *testing.T) {
+	cases := map[slog.Level]Level{
+		slog.LevelDebug - 8: LevelDebug4,
+		slog.LevelDebug - 4: LevelDebug4,
+		slog.LevelDebug - 1: LevelDebug1,
+		slog.LevelDebug:     LevelDebug,
+		slog.LevelInfo:      LevelInfo,
+		slog.LevelInfo + 1:  LevelInfo,
+		slog.LevelWarn:      LevelWarn,
+		slog.LevelError:     LevelError,
+		slog.LevelError + 4: LevelCrit,
+	}
+	for in, want := range cases {
+		if got := SlogLevel(in); got != want {
+			t.Errorf("SlogLevel(%v) = %v, want %v", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"bytes"
+	"log"
+	"runtime"
+)
+
+// StdWriter is an io.Writer logging every write, a line of the standard log
+// package, at a fixed level.
+type StdWriter struct {
+	n     *NamedLogger
+	level Level
+}
+
+// NewStdWriter returns a StdWriter logging through n at level, for
+// log.SetOutput or log.New. The standard log flags are best left to 0, n
+// writing the time and caller itself.
+func NewStdWriter(n *NamedLogger, level Level) *StdWriter {
+	return &StdWriter{n: n, level: level}
+}
+
+// RedirectStdLog sends the output of the standard log package to n, at
+// level.
+func RedirectStdLog(n *NamedLogger, level Level) {
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(NewStdWriter(n, level))
+}
+
+func (w *StdWriter) Write(p []byte) (int, error) {
+	c := w.n.c
+	if !c.enabled(w.n.name, w.level) {
+		return len(p), nil
+	}
+
+	s := c.sampling()
+	var pc uintptr
+	if c.enable_caller_info || s != nil {
+		pc = std_caller_pc()
+	}
+
+	msg := string(bytes.TrimSuffix(p, []byte{'\n'}))
+	c.log_pc(s, pc, w.n.name, w.n.fields, w.level, "%s", msg)
+	return len(p), nil
+}
+
+// std_caller_pc returns the program counter of the first caller of Write
+// outside the log package.
+func std_caller_pc() uintptr {
+	var pcs [16]uintptr
+	n := runtime.Callers(3, pcs[:])
+
+	frames := runtime.CallersFrames(pcs[:n])
+	for skip := 3; ; skip++ {
+		frame, more := frames.Next()
+		if pkg, _ := split_func_name(frame.Function); pkg != "log" {
+			// frames may outnumber pcs when inlined, look it up again
+			var pc [1]uintptr
+			runtime.Callers(skip, pc[:])
+			return pc[0]
+		}
+		if !more {
+			return 0
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package logger
+
+import (
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStdWriter(t *testing.T) {
+	w := &buffer_closer{}
+	l := NewSimpleLogger(w)
+	l.EnableCallerInfo()
+
+	std := log.New(NewStdWriter(l.Named("std"), LevelWarn), "", 0)
+	std.Printf("a %d", 1)
+
+	RedirectStdLog(l.Named("global"), LevelInfo)
+	defer log.SetOutput(os.Stderr)
+	log.Println("b")
+
+	l.SetLevel(LevelError)
+	l.Levels().SetLevel("std", LevelError)
+	std.Print("filtered")
+
+	lines := strings.Split(strings.TrimSpace(w.buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines:\n%s", len(lines), w.buf.Bytes())
+	}
+	if !strings.Contains(lines[0], "[WARN] || {std} [") || !strings.HasSuffix(lines[0], "] a 1") {
+		t.Errorf("line 1: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "[INFO] || {global} [") || !strings.HasSuffix(lines[1], "] b") {
+		t.Errorf("line 2: %s", lines[1])
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "stdlog_test.go:TestStdWriter(..):") {
+			t.Errorf("caller is not the test: %s", line)
+		}
+	}
+}
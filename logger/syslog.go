@@ -0,0 +1,218 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// SyslogFacility is a syslog facility. The kernel facility, 0, is left out
+// as it is reserved to the kernel: 0 stands for the default, FacilityUser.
+type SyslogFacility uint8
+
+const (
+	FacilityUser   SyslogFacility = 1
+	FacilityDaemon SyslogFacility = 3
+	FacilityLocal0 SyslogFacility = 16
+	FacilityLocal1 SyslogFacility = 17
+	FacilityLocal2 SyslogFacility = 18
+	FacilityLocal3 SyslogFacility = 19
+	FacilityLocal4 SyslogFacility = 20
+	FacilityLocal5 SyslogFacility = 21
+	FacilityLocal6 SyslogFacility = 22
+	FacilityLocal7 SyslogFacility = 23
+)
+
+const (
+	syslog_time_format            = "2006-01-02T15:04:05.000000Z07:00"
+	default_syslog_retry_interval = 5 * time.Second
+)
+
+var ErrSyslogUnavailable = errors.New("syslog server unavailable")
+
+// syslog_severity maps a Level to a syslog severity, all debug levels
+// become LOG_DEBUG.
+func syslog_severity(lv Level) int {
+	switch {
+	case lv >= LevelCrit:
+		return 2
+	case lv == LevelError:
+		return 3
+	case lv == LevelWarn:
+		return 4
+	case lv == LevelInfo:
+		return 6
+	}
+	return 7
+}
+
+type SyslogConfig struct {
+	// Network is one of "udp", "tcp", "unix" or "unixgram". Stream
+	// connections use octet-counting framing (RFC 6587).
+	Network string
+	Addr    string
+	// Facility defaults to FacilityUser.
+	Facility SyslogFacility
+	// Hostname defaults to os.Hostname().
+	Hostname string
+	// AppName defaults to Entry.System, then to the program name.
+	AppName string
+	// RetryInterval is the delay after a failed connection before the
+	// next attempt, 5s by default. Entries written meanwhile are dropped
+	// with ErrSyslogUnavailable.
+	RetryInterval time.Duration
+}
+
+// SyslogSink sends entries as RFC 5424 messages. MSGID carries the level
+// name, so DEBUG1..DEBUG4 stay distinguishable under LOG_DEBUG.
+type SyslogSink struct {
+	cfg    SyslogConfig
+	stream bool
+	pid    string
+	conn   net.Conn
+	buf    []byte
+	msg    []byte
+
+	next_retry time.Time
+}
+
+func NewSyslogSink(cfg *SyslogConfig) (*SyslogSink, error) {
+	s := &SyslogSink{
+		cfg: *cfg,
+		pid: strconv.Itoa(os.Getpid()),
+	}
+
+	switch s.cfg.Network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		s.stream = true
+	case "udp", "udp4", "udp6", "unixgram":
+	default:
+		return nil, fmt.Errorf("syslog: unsupported network %q", s.cfg.Network)
+	}
+
+	if s.cfg.Facility == 0 {
+		s.cfg.Facility = FacilityUser
+	}
+	if s.cfg.Hostname == "" {
+		s.cfg.Hostname, _ = os.Hostname()
+	}
+	if s.cfg.RetryInterval <= 0 {
+		s.cfg.RetryInterval = default_syslog_retry_interval
+	}
+
+	if err := s.dial(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SyslogSink) dial() error {
+	conn, err := net.DialTimeout(s.cfg.Network, s.cfg.Addr, 5*time.Second)
+	if err != nil {
+		s.next_retry = time.Now().Add(s.cfg.RetryInterval)
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *SyslogSink) WriteEntry(e *Entry) error {
+	s.buf = s.format(s.buf[:0], e)
+
+	var err error
+	if s.conn != nil {
+		if _, err = s.conn.Write(s.buf); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+
+	// reconnect once, the server may have restarted, unless the last
+	// attempt failed lately
+	if time.Now().Before(s.next_retry) {
+		return ErrSyslogUnavailable
+	}
+	if err = s.dial(); err != nil {
+		return err
+	}
+	_, err = s.conn.Write(s.buf)
+	return err
+}
+
+func (s *SyslogSink) format(b []byte, e *Entry) []byte {
+	app := s.cfg.AppName
+	if app == "" {
+		app = e.System
+	}
+	if app == "" {
+		app = filepath.Base(os.Args[0])
+	}
+
+	msg := s.msg[:0]
+	msg = append(msg, '<')
+	msg = strconv.AppendInt(msg, int64(s.cfg.Facility)*8+int64(syslog_severity(e.Level)), 10)
+	msg = append(msg, ">1 "...)
+	msg = e.Time.AppendFormat(msg, syslog_time_format)
+	msg = append(msg, ' ')
+	msg = append(msg, syslog_header_field(s.cfg.Hostname)...)
+	msg = append(msg, ' ')
+	msg = append(msg, syslog_header_field(app)...)
+	msg = append(msg, ' ')
+	msg = append(msg, s.pid...)
+	msg = append(msg, ' ')
+	msg = append(msg, e.Level.String()...)
+	msg = append(msg, " - "...)
+	if e.Name != "" {
+		msg = append(msg, '{')
+		msg = append(msg, e.Name...)
+		msg = append(msg, "} "...)
+	}
+	if e.Caller != "" {
+		msg = append(msg, '[')
+		msg = append(msg, e.Caller...)
+		msg = append(msg, "] "...)
+	}
+	msg = append(msg, e.Message...)
+	msg = append_logfmt_fields(msg, e.Fields)
+
+	s.msg = msg
+
+	if s.stream {
+		b = strconv.AppendInt(b, int64(len(msg)), 10)
+		b = append(b, ' ')
+	}
+	return append(b, msg...)
+}
+
+// syslog_header_field replaces characters not allowed in header fields.
+func syslog_header_field(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	b := []byte(s)
+	for i, c := range b {
+		if c <= ' ' || c > '~' {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func (s *SyslogSink) Flush() error {
+	return nil
+}
+
+func (s *SyslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,123 @@
+package logger
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var syslog_entry = &Entry{
+	Time:    time.Date(2017, 7, 28, 17, 14, 36, 928000000, time.UTC),
+	Level:   LevelDebug3,
+	System:  "DEMO",
+	Caller:  "main:demo.go:main(..):35",
+	Message: "hello",
+}
+
+const syslog_want = "<15>1 2017-07-28T17:14:36.928000Z host DEMO "
+
+func TestSyslogSinkUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	s, err := NewSyslogSink(&SyslogConfig{Network: "udp", Addr: pc.LocalAddr().String(), Hostname: "host"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.WriteEntry(syslog_entry); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1024)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := string(buf[:n])
+	if !strings.HasPrefix(msg, syslog_want) || !strings.HasSuffix(msg, " DEBUG3 - [main:demo.go:main(..):35] hello") {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestSyslogSinkTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	s, err := NewSyslogSink(&SyslogConfig{Network: "tcp", Addr: ln.Addr().String(), Hostname: "host", Facility: FacilityLocal0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	e := *syslog_entry
+	e.Level = LevelError
+	s.WriteEntry(&e)
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	size, err := r.ReadString(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(size))
+	if err != nil {
+		t.Fatalf("bad octet count %q", size)
+	}
+
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(msg), "<131>1 ") || !strings.HasSuffix(string(msg), " ERROR - [main:demo.go:main(..):35] hello") {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestSyslogSinkRetry(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewSyslogSink(&SyslogConfig{Network: "tcp", Addr: ln.Addr().String(), RetryInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// the server goes away
+	ln.Close()
+	s.conn.Close()
+	s.conn = nil
+
+	if err := s.WriteEntry(syslog_entry); err == nil || err == ErrSyslogUnavailable {
+		t.Fatalf("first write error %v, want the dial error", err)
+	}
+	start := time.Now()
+	if err := s.WriteEntry(syslog_entry); err != ErrSyslogUnavailable {
+		t.Fatalf("second write error %v, want %v", err, ErrSyslogUnavailable)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("second write took %v", d)
+	}
+}
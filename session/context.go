@@ -0,0 +1,28 @@
+package mi_session
+
+import (
+	"context"
+
+	"github.com/stormgbs/gopkg/logger"
+)
+
+type context_key int
+
+const user_session_context_key context_key = 0
+
+func init() {
+	logger.RegisterContextKeyFunc(user_session_context_key, "user", func(v interface{}) string {
+		return v.(*UserSession).CasUsername
+	})
+}
+
+// NewContext returns a copy of ctx carrying u, whose user name is then added
+// to every entry logged through logger.FromContext.
+func NewContext(ctx context.Context, u *UserSession) context.Context {
+	return context.WithValue(ctx, user_session_context_key, u)
+}
+
+func FromContext(ctx context.Context) (*UserSession, bool) {
+	u, ok := ctx.Value(user_session_context_key).(*UserSession)
+	return u, ok
+}
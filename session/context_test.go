@@ -0,0 +1,38 @@
+package mi_session
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stormgbs/gopkg/logger"
+)
+
+type buffer_closer struct {
+	bytes.Buffer
+}
+
+func (*buffer_closer) Close() error { return nil }
+
+func TestContextUser(t *testing.T) {
+	w := &buffer_closer{}
+	l := logger.NewSimpleLogger(w)
+
+	u := &UserSession{CasUsername: "alice"}
+	ctx := logger.NewContext(NewContext(context.Background(), u), l.Named(""))
+	logger.FromContext(ctx).Info("hello")
+
+	if out := w.String(); !strings.HasSuffix(out, "hello user=alice\n") {
+		t.Errorf("unexpected output %q", out)
+	}
+	if got, ok := FromContext(ctx); !ok || got != u {
+		t.Errorf("FromContext = %v, %v", got, ok)
+	}
+
+	// the user field must not change how a session prints elsewhere
+	if s := fmt.Sprint(u); s != "&{alice}" {
+		t.Errorf("Sprint = %q", s)
+	}
+}